@@ -0,0 +1,175 @@
+package ebml
+
+import (
+	"io"
+	"math/bits"
+	"slices"
+	"sync"
+)
+
+// A BinaryMode controls the storage of binary values decoded into []byte.
+type BinaryMode int
+
+const (
+	// BinaryCopy stores each binary value in a separate slice provided
+	// by the Allocator of the Decoder. The caller owns the slice.
+	BinaryCopy BinaryMode = iota
+	// BinaryAlias stores binary values in an internal buffer of the
+	// Decoder. A value is only valid until the next call to Decode,
+	// DecodeHeader or DecodeBody. Use it when the values are consumed
+	// before decoding continues, to avoid an allocation per value.
+	BinaryAlias
+)
+
+// An Allocator provides the memory for binary values decoded
+// with BinaryCopy. Values larger than 16 MiB are not allocated before
+// they are read, the Decoder grows their slice as the data comes in.
+type Allocator interface {
+	// Alloc returns a slice of length n.
+	Alloc(n int) []byte
+}
+
+type makeAllocator struct{}
+
+func (makeAllocator) Alloc(n int) []byte {
+	return make([]byte, n)
+}
+
+// A PoolAllocator is an Allocator which reuses slices released with Free.
+// Slices are pooled in power of two size classes backed by sync.Pool.
+//
+// The zero value is ready to use. A PoolAllocator is safe for concurrent use.
+type PoolAllocator struct {
+	pools [maxPoolClass + 1]sync.Pool
+}
+
+// maxPoolClass is the largest size class, 1<<maxPoolClass octets, served
+// from a pool. Larger slices are allocated on every call.
+const maxPoolClass = 26
+
+func poolClass(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	return bits.Len(uint(n - 1))
+}
+
+// Alloc returns a slice of length n. The capacity of the slice can be
+// greater than n.
+func (a *PoolAllocator) Alloc(n int) []byte {
+	c := poolClass(n)
+	if c > maxPoolClass {
+		return make([]byte, n)
+	}
+	if p, ok := a.pools[c].Get().(*[]byte); ok {
+		return (*p)[:n]
+	}
+	return make([]byte, n, 1<<c)
+}
+
+// Free makes b available to later calls of Alloc. The caller must not
+// use b after calling Free. Slices which were not returned by Alloc
+// are ignored.
+func (a *PoolAllocator) Free(b []byte) {
+	c := poolClass(cap(b))
+	if c > maxPoolClass || cap(b) != 1<<c {
+		return
+	}
+	b = b[:0]
+	a.pools[c].Put(&b)
+}
+
+// A Buffer is a binary value which reuses its own storage. Decoding into
+// a Buffer overwrites its content and only allocates when the capacity of
+// the Buffer is too small, which makes it possible to decode into
+// caller-provided memory.
+type Buffer []byte
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (b *Buffer) UnmarshalBinary(data []byte) error {
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+// SetBinaryMode sets the storage of binary values decoded into []byte.
+// The default is BinaryCopy.
+func (d *Decoder) SetBinaryMode(m BinaryMode) {
+	d.binaryMode = m
+}
+
+// SetAllocator sets the Allocator used by BinaryCopy. A nil Allocator
+// restores the default which allocates with make.
+func (d *Decoder) SetAllocator(a Allocator) {
+	if a == nil {
+		a = makeAllocator{}
+	}
+	d.alloc = a
+}
+
+// allocBinary returns a slice of length n which can be stored
+// in a decoded value.
+func (d *Decoder) allocBinary(n int64) []byte {
+	if d.binaryMode != BinaryAlias {
+		return d.alloc.Alloc(int(n))
+	}
+	if int64(cap(d.arena)-len(d.arena)) < n {
+		size := DefaultAllocationWindow
+		for size < n {
+			size = (size << 1) + 1
+		}
+		d.arena = make([]byte, 0, size)
+	}
+	i := len(d.arena)
+	d.arena = d.arena[:i+int(n)]
+	return d.arena[i:len(d.arena):len(d.arena)]
+}
+
+// scratch returns a temporary slice of length n which is only valid until
+// the next read.
+func (d *Decoder) scratch(n int64) []byte {
+	if int64(cap(d.window)) < n {
+		d.window = make([]byte, max(n, minScratchSize))
+	}
+	return d.window[:n]
+}
+
+const minScratchSize = 512
+
+// maxPrealloc is the largest Element Data Size which is allocated before
+// the data is read. Larger data is first read in growing chunks, so that a
+// damaged or hostile Element Data Size cannot make the Decoder allocate
+// more memory than the input holds.
+const maxPrealloc = 16 << 20
+
+// readAlloc reads the data of el into a slice of length el.DataSize
+// returned by alloc, which is d.scratch or d.allocBinary. Data larger than
+// maxPrealloc is read into a slice which grows as the data is read, and
+// which is returned instead of a slice from alloc.
+func (d *Decoder) readAlloc(el Element, alloc func(n int64) []byte) ([]byte, error) {
+	if el.DataSize == -1 {
+		return nil, newElementError(el, ErrUnknownSizeNotAllowed)
+	}
+	if el.DataSize <= maxPrealloc {
+		b := alloc(el.DataSize)
+		if err := d.readData(el, b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	b := make([]byte, 0, maxPrealloc)
+	for int64(len(b)) < el.DataSize {
+		if len(b) == cap(b) {
+			b = slices.Grow(b, int(min(el.DataSize, int64(cap(b))*2))-len(b))
+		}
+		n := min(int64(cap(b)), el.DataSize)
+		m, err := io.ReadFull(d.r, b[len(b):n])
+		b = b[:len(b)+m]
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, newElementError(el, err)
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,114 @@
+package ebml
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestDecoder_SetBinaryMode(t *testing.T) {
+	body := testElement(testIDTest,
+		testElement(testIDCluster,
+			testElement(testIDTimestamp, []byte{1}),
+			testElement(testIDPayload, []byte("abc")),
+			testElement(testIDPayload, []byte("def")),
+		),
+	)
+	tests := []struct {
+		name      string
+		mode      BinaryMode
+		alloc     Allocator
+		wantAlias bool
+	}{
+		{name: "copy", mode: BinaryCopy},
+		{name: "copy with pool", mode: BinaryCopy, alloc: &PoolAllocator{}},
+		{name: "alias", mode: BinaryAlias, wantAlias: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(bytes.NewReader(append(testHeader("test"), body...)))
+			d.SetBinaryMode(tt.mode)
+			d.SetAllocator(tt.alloc)
+			if _, err := d.DecodeHeader(); err != nil {
+				t.Fatal(err)
+			}
+			var doc testDocument
+			if err := d.DecodeBody(&doc); err != nil {
+				t.Fatal(err)
+			}
+			p := doc.Cluster[0].Payload
+			if len(p) != 2 || string(p[0]) != "abc" || string(p[1]) != "def" {
+				t.Fatalf("Payload = %q, want [abc def]", p)
+			}
+			aliased := len(d.arena) == 6 && &d.arena[0] == &p[0][0] && &d.arena[3] == &p[1][0]
+			if aliased != tt.wantAlias {
+				t.Errorf("Payload values alias the decoder = %v, want %v", aliased, tt.wantAlias)
+			}
+			if cap(p[0]) < len(p[0]) || tt.wantAlias && cap(p[0]) != len(p[0]) {
+				t.Errorf("cap(Payload[0]) = %d, want %d", cap(p[0]), len(p[0]))
+			}
+		})
+	}
+}
+
+func TestDecoder_readAlloc(t *testing.T) {
+	size := int64(2*maxPrealloc + 3)
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	el := Element{ID: testIDPayload, DataSize: size}
+
+	d := NewDecoder(bytes.NewReader(data))
+	b, err := d.readAlloc(el, d.scratch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Errorf("readAlloc() = %d octets, want the %d octets of the input", len(b), len(data))
+	}
+
+	d = NewDecoder(bytes.NewReader(data[:maxPrealloc+1]))
+	if _, err := d.readAlloc(el, d.scratch); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("readAlloc() error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestBuffer_UnmarshalBinary(t *testing.T) {
+	var doc struct {
+		Cluster struct {
+			Payload Buffer
+		}
+	}
+	backing := make(Buffer, 0, 16)
+	doc.Cluster.Payload = backing
+	body := testElement(testIDTest, testElement(testIDCluster, testElement(testIDPayload, []byte("abc"))))
+	d := NewDecoder(bytes.NewReader(append(testHeader("test"), body...)))
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DecodeBody(&doc); err != nil {
+		t.Fatal(err)
+	}
+	got := doc.Cluster.Payload
+	if string(got) != "abc" {
+		t.Fatalf("Payload = %q, want %q", got, "abc")
+	}
+	if &got[0] != &backing[:1][0] {
+		t.Errorf("Payload does not use the provided buffer")
+	}
+}
+
+func TestPoolAllocator(t *testing.T) {
+	var a PoolAllocator
+	b := a.Alloc(100)
+	if len(b) != 100 || cap(b) != 128 {
+		t.Fatalf("Alloc(100) = len %d cap %d, want len 100 cap 128", len(b), cap(b))
+	}
+	a.Free(b)
+	a.Free(make([]byte, 100)) // ignored
+	if b := a.Alloc(0); len(b) != 0 {
+		t.Errorf("Alloc(0) = len %d, want 0", len(b))
+	}
+}
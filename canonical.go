@@ -0,0 +1,201 @@
+package ebml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/coding-socks/ebml/ebmltext"
+	"github.com/coding-socks/ebml/schema"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// ErrNotCanonical signals an element which is not in its canonical form.
+var ErrNotCanonical = errors.New("ebml: not canonical")
+
+// SetCanonical sets whether e writes the canonical form of the document,
+// which makes the output byte-stable for a given content:
+//
+//   - Element IDs and data sizes use their shortest form. Element IDs
+//     which have a shorter form are rejected, and the data size of a
+//     master element is never patched.
+//   - Integers, unsigned integers, floats and dates use their shortest
+//     form, which is zero octets for a zero value. Floats use four octets
+//     when it does not lose precision. Strings are written without
+//     trailing zero octets.
+//   - Elements which can occur at most once are omitted when they are
+//     equal to their default value.
+//
+// Decoder.CheckCanonical reports the elements of a document which are
+// not in this form.
+func (e *Encoder) SetCanonical(canonical bool) {
+	e.canonical = canonical
+}
+
+// CheckCanonical reads the rest of the input and reports the elements
+// which are not in the canonical form written by an Encoder with
+// SetCanonical. The reported errors wrap ErrNotCanonical, unless the
+// value of the element is invalid.
+//
+// When the input starts with an EBML Header, CheckCanonical uses the
+// definition of its DocType to check the EBML Body.
+func (d *Decoder) CheckCanonical() ([]*ElementError, error) {
+	var (
+		found   []*ElementError
+		docType string
+		idLen   = uint64(DefaultMaxIDLength)
+		sizeLen = uint64(DefaultMaxSizeLength)
+	)
+	report := func(el Element, err error) {
+		found = append(found, newElementError(el, err))
+	}
+	var check func(el Element) error
+	check = func(el Element) error {
+		idW := (bits.Len64(uint64(el.ID)) + 7) / 8
+		if w := vintDataWidth(uint64(el.ID) &^ (1 << (7 * idW))); w < idW {
+			report(el, fmt.Errorf("%w: element ID uses %d octets, %d are enough", ErrNotCanonical, idW, w))
+		}
+		sizeW := el.HeaderSize - idW
+		if el.DataSize == -1 {
+			if sizeW > 1 {
+				report(el, fmt.Errorf("%w: unknown data size uses %d octets, 1 is enough", ErrNotCanonical, sizeW))
+			}
+		} else if w := vintDataWidth(uint64(el.DataSize)); w < sizeW {
+			report(el, fmt.Errorf("%w: data size uses %d octets, %d are enough", ErrNotCanonical, sizeW, w))
+		}
+
+		switch el.Schema.Type {
+		case TypeMaster:
+			return d.DecodeChildren(el, check)
+		case "":
+			return d.Skip(el)
+		}
+		b, err := d.readAlloc(el, d.scratch)
+		if err != nil {
+			return err
+		}
+		c, err := appendCanonical(nil, el.Schema.Type, b)
+		if err != nil {
+			report(el, err)
+			return nil
+		}
+		if !bytes.Equal(b, c) {
+			report(el, fmt.Errorf("%w: %s value uses %d octets, %d are enough", ErrNotCanonical, el.Schema.Type, len(b), len(c)))
+		}
+		if isDefault(el.Schema, c) {
+			report(el, fmt.Errorf("%w: element has its default value", ErrNotCanonical))
+		}
+		switch el.ID {
+		case IDDocType:
+			docType = string(c)
+		case IDEBMLMaxIDLength:
+			idLen, _ = ebmltext.Uint(c)
+		case IDEBMLMaxSizeLength:
+			sizeLen, _ = ebmltext.Uint(c)
+		}
+		return nil
+	}
+
+	d.skippedErrs = nil
+	for {
+		el, _, err := d.NextOf(RootEl, 0)
+		if err == io.EOF {
+			return found, d.skippedErrs
+		}
+		if err != nil {
+			return found, err
+		}
+		if el.ID != IDEBML {
+			if err := check(el); err != nil {
+				return found, err
+			}
+			continue
+		}
+		d.def = HeaderDef
+		d.r.MaxIDLength = DefaultMaxIDLength
+		d.r.MaxSizeLength = DefaultMaxSizeLength
+		if err := check(el); err != nil {
+			return found, err
+		}
+		if d.def, err = Definition(docType); err != nil {
+			return found, err
+		}
+		d.r.MaxIDLength, d.r.MaxSizeLength = uint(idLen), uint(sizeLen)
+	}
+}
+
+// vintDataWidth returns the number of octets of the shortest VINT which
+// represents v. The VINT_DATA with all bits set to one is reserved.
+func vintDataWidth(v uint64) int {
+	w := 1
+	for w < 8 && v >= 1<<(7*w)-1 {
+		w++
+	}
+	return w
+}
+
+// isCanonicalID reports whether id uses its shortest form.
+func isCanonicalID(id schema.ElementID) bool {
+	w := (bits.Len64(uint64(id)) + 7) / 8
+	return vintDataWidth(uint64(id)&^(1<<(7*w))) == w
+}
+
+// appendCanonical appends the canonical form of data, an element of type
+// typ, to b.
+func appendCanonical(b []byte, typ string, data []byte) ([]byte, error) {
+	switch typ {
+	case TypeInteger:
+		i, err := ebmltext.Int(data)
+		if err != nil || i == 0 {
+			return b, err
+		}
+		return ebmltext.AppendInt(b, i), nil
+	case TypeUinteger:
+		u, err := ebmltext.Uint(data)
+		if err != nil || u == 0 {
+			return b, err
+		}
+		return ebmltext.AppendUint(b, u), nil
+	case TypeFloat:
+		f, err := ebmltext.Float(data)
+		if err != nil {
+			return b, err
+		}
+		return appendCanonicalFloat(b, f), nil
+	case TypeDate:
+		if len(data) != 0 && len(data) != 8 {
+			return b, errors.New("ebml: data length must be 0 bit or 64 bit for a date")
+		}
+		if i, _ := ebmltext.Int(data); i == 0 {
+			return b, nil
+		}
+		return append(b, data...), nil
+	case TypeString, TypeUTF8:
+		return append(b, bytes.TrimRight(data, "\x00")...), nil
+	}
+	return append(b, data...), nil
+}
+
+// appendCanonicalFloat appends the shortest form of f which does not
+// lose precision to b.
+func appendCanonicalFloat(b []byte, f float64) []byte {
+	switch {
+	case f == 0 && !math.Signbit(f):
+		return b
+	case float64(float32(f)) == f:
+		return ebmltext.AppendFloat(b, f, 4)
+	}
+	return ebmltext.AppendFloat(b, f, 8)
+}
+
+// isDefault reports whether the canonical data c is the default value of
+// sch, which makes the element omittable. Elements which can occur more
+// than once are never omittable.
+func isDefault(sch schema.Element, c []byte) bool {
+	if sch.Default == nil || sch.MaxOccurs.Unbounded() || sch.MaxOccurs.Val() > 1 {
+		return false
+	}
+	def, err := defaultData(sch)
+	return err == nil && bytes.Equal(def, c)
+}
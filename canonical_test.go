@@ -0,0 +1,146 @@
+package ebml
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/ebml/ebmltext"
+	"github.com/coding-socks/ebml/schema"
+)
+
+func encodeTestDocument(t *testing.T, canonical bool, doc testDocument) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetCanonical(canonical)
+	if err := enc.EncodeHeader(&EBML{EBMLVersion: 1, EBMLReadVersion: 1, DocType: "test", EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.EncodeBody(doc); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEncoder_SetCanonical(t *testing.T) {
+	doc := testDocument{
+		Info: testInfo{
+			Title:          "title",
+			TimestampScale: 1000000,
+			Duration:       12.5,
+			DateUTC:        time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Offset:         -1,
+		},
+		Cluster: []testCluster{{Timestamp: 0, Payload: [][]byte{{1}}}},
+	}
+	canonical := encodeTestDocument(t, true, doc)
+	regular := encodeTestDocument(t, false, doc)
+	if len(canonical) >= len(regular) {
+		t.Errorf("len(canonical) = %d, want less than %d", len(canonical), len(regular))
+	}
+
+	d := NewDecoder(bytes.NewReader(canonical))
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var got testDocument
+	if err := d.DecodeBody(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("DecodeBody() = %+v, want %+v", got, doc)
+	}
+
+	found, err := NewDecoder(bytes.NewReader(canonical)).CheckCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range found {
+		t.Errorf("CheckCanonical(canonical) reported %v", e)
+	}
+
+	found, err = NewDecoder(bytes.NewReader(regular)).CheckCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[schema.ElementID]string{
+		IDEBMLVersion:        "default value",
+		IDEBMLReadVersion:    "default value",
+		IDEBMLMaxIDLength:    "default value",
+		IDEBMLMaxSizeLength:  "default value",
+		IDDocTypeVersion:     "uinteger value uses 1 octets, 0 are enough",
+		IDDocTypeReadVersion: "uinteger value uses 1 octets, 0 are enough",
+		testIDTimestampScale: "default value",
+		testIDOffset:         "default value",
+		testIDDuration:       "float value uses 8 octets, 4 are enough",
+		testIDTimestamp:      "uinteger value uses 1 octets, 0 are enough",
+	}
+	for _, e := range found {
+		if !errors.Is(e, ErrNotCanonical) {
+			t.Errorf("CheckCanonical() reported %v, want ErrNotCanonical", e)
+		}
+		msg, ok := want[e.ID]
+		if !ok || !strings.Contains(e.Error(), msg) {
+			t.Errorf("CheckCanonical() reported %v", e)
+		}
+		delete(want, e.ID)
+	}
+	for id, msg := range want {
+		t.Errorf("CheckCanonical() did not report %v: %s", id, msg)
+	}
+}
+
+func TestDecoder_CheckCanonical(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(testHeader("test"))
+	enc := ebmltext.NewEncoder(&buf)
+	if _, err := enc.WriteElementID(testIDTest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.WriteElementDataSize(-1, 8); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.WriteElementID(testIDCluster); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.WriteElementDataSize(4, 8); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(testElement(testIDTimestamp, []byte{0, 1}))
+
+	found, err := NewDecoder(bytes.NewReader(buf.Bytes())).CheckCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"element has its default value", // EBMLMaxIDLength
+		"element has its default value", // EBMLMaxSizeLength
+		"unknown data size uses 8 octets, 1 is enough",
+		"data size uses 8 octets, 1 are enough",
+		"uinteger value uses 2 octets, 1 are enough",
+	}
+	if len(found) != len(want) {
+		t.Fatalf("CheckCanonical() = %v, want %d errors", found, len(want))
+	}
+	for i, e := range found {
+		if !strings.Contains(e.Error(), want[i]) {
+			t.Errorf("CheckCanonical()[%d] = %v, want %q", i, e, want[i])
+		}
+	}
+}
+
+func TestEncoder_SetCanonical_id(t *testing.T) {
+	enc := NewEncoder(io.Discard)
+	enc.SetCanonical(true)
+	if err := enc.WriteBinary(0x4001, nil); !errors.Is(err, ErrNotCanonical) {
+		t.Errorf("WriteBinary(0x4001) error = %v, want ErrNotCanonical", err)
+	}
+	if err := enc.WriteBinary(0x81, nil); err != nil {
+		t.Errorf("WriteBinary(0x81) error = %v", err)
+	}
+}
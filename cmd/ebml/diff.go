@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/coding-socks/ebml"
+)
+
+// errDiffer makes the diff command exit with a non-zero status after it
+// printed the differences.
+var errDiffer = errors.New("documents differ")
+
+func runDiff(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ebml diff [flags] file1 file2")
+		fs.PrintDefaults()
+	}
+	var opts ebml.DiffOptions
+	fs.BoolVar(&opts.IgnoreVoid, "ignore-void", false, "ignore Void elements")
+	fs.BoolVar(&opts.IgnoreCRC, "ignore-crc", false, "ignore CRC-32 elements")
+	fs.BoolVar(&opts.IgnoreDataSize, "ignore-size", false, "ignore the width of data sizes and unknown data sizes")
+	layout := fs.Bool("ignore-layout", false, "same as -ignore-void -ignore-crc -ignore-size")
+	asJSON := fs.Bool("json", false, "print the changes as JSON")
+	fs.Var(schemaFlag{}, "schema", "register the EBML schema in `file` (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *layout {
+		opts = ebml.DiffOptions{IgnoreVoid: true, IgnoreCRC: true, IgnoreDataSize: true}
+	}
+	a, err := openInput(fs.Args()[:1])
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	b, err := openInput(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	changes, err := ebml.Diff(a, b, opts)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(stdout)
+	if *asJSON {
+		if changes == nil {
+			changes = []ebml.Change{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(changes)
+	} else {
+		for _, c := range changes {
+			if _, err = fmt.Fprintln(w, c); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		return errDiffer
+	}
+	return nil
+}
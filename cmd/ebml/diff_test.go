@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/coding-socks/ebml"
+)
+
+func TestDiff(t *testing.T) {
+	a := writeTestFile(t)
+	var out bytes.Buffer
+	if err := runDiff([]string{a, a}, &out); err != nil || out.Len() != 0 {
+		t.Fatalf("runDiff(a, a) = %q, %v", out.Bytes(), err)
+	}
+
+	doc, err := os.ReadFile(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := a + ".b"
+	if err := os.WriteFile(b, bytes.Replace(doc, []byte("title"), []byte("other"), 1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := runDiff([]string{a, b}, &out); !errors.Is(err, errDiffer) {
+		t.Fatalf("runDiff(a, b) error = %v, want errDiffer", err)
+	}
+	if got, want := out.String(), "~ \\Test\\Info\\Title (a: 46, b: 46) value: \"title\" -> \"other\"\n"; got != want {
+		t.Errorf("runDiff(a, b) = %q, want %q", got, want)
+	}
+
+	out.Reset()
+	if err := runDiff([]string{"-json", "-ignore-layout", a, b}, &out); !errors.Is(err, errDiffer) {
+		t.Fatalf("runDiff(-json, a, b) error = %v, want errDiffer", err)
+	}
+	var changes []ebml.Change
+	if err := json.Unmarshal(out.Bytes(), &changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Kind != ebml.ChangeModified || changes[0].B != `"other"` {
+		t.Errorf("changes = %+v", changes)
+	}
+}
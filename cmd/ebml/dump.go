@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coding-socks/ebml"
+)
+
+// maxBinaryDump is the number of octets of a binary value printed by dump.
+const maxBinaryDump = 16
+
+// maxBinaryRead is the size of the largest binary value read by dump.
+// The data of larger binary elements is skipped.
+const maxBinaryRead = 1 << 20
+
+func runDump(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ebml dump [flags] [file]")
+		fs.PrintDefaults()
+	}
+	dm := dumper{}
+	fs.IntVar(&dm.maxDepth, "max-depth", -1, "print only the elements up to `depth`; 0 prints the top-level elements")
+	fs.Var(&dm.paths, "path", "print only the elements at or below the schema `path`, such as \\Segment\\Info (repeatable)")
+	fs.BoolVar(&dm.showVoid, "show-void", false, "print Void elements")
+	fs.Var(schemaFlag{}, "schema", "register the EBML schema in `file` (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	r, err := openInput(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	bw := bufio.NewWriter(stdout)
+	dm.w = bw
+	err = dm.dump(r)
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// A dumper prints the elements of a document, one per line, with the
+// offset, the header size and the data size of the element.
+type dumper struct {
+	w        io.Writer
+	d        *ebml.Decoder
+	maxDepth int
+	paths    stringsFlag
+	showVoid bool
+}
+
+// headerRecorder records the elements of the EBML Header with their depth
+// and their values while they are decoded.
+type headerRecorder struct {
+	els    []ebml.Element
+	depths []int
+	values map[int64]any
+}
+
+func (r *headerRecorder) Visit(path []ebml.Element, el ebml.Element) ebml.VisitAction {
+	r.els = append(r.els, el)
+	r.depths = append(r.depths, len(path))
+	return ebml.VisitContinue
+}
+
+func (r *headerRecorder) Decoded(path []ebml.Element, el ebml.Element, val any) ebml.VisitAction {
+	if el.Schema.Type != ebml.TypeMaster {
+		r.values[el.Offset] = val
+	}
+	return ebml.VisitContinue
+}
+
+func (dm *dumper) dump(r io.Reader) error {
+	dm.d = ebml.NewDecoder(r)
+	fmt.Fprintf(dm.w, "%10s %4s %10s  %s\n", "offset", "hdr", "size", "element")
+
+	rec := &headerRecorder{values: make(map[int64]any)}
+	dm.d.SetVisitor(rec, ebml.Filter{})
+	_, err := dm.d.DecodeHeader()
+	dm.d.SetVisitor(nil, ebml.Filter{})
+	for i, el := range rec.els {
+		depth := rec.depths[i]
+		if dm.show(el, depth, len(dm.paths) == 0) {
+			val, ok := rec.values[el.Offset]
+			dm.print(el, depth, val, ok)
+		}
+	}
+	if unknown := (ebml.UnknownDocTypeError{}); errors.As(err, &unknown) {
+		log.Printf("%v; the EBML Body is printed with raw IDs", err)
+	} else if err != nil {
+		return err
+	}
+
+	for {
+		el, _, err := dm.d.NextOf(ebml.RootEl, 0)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := dm.visit(el, 0, len(dm.paths) == 0); err != nil {
+			return err
+		}
+	}
+}
+
+// visit prints el at depth and its children. The parent of el is printed
+// when parentShown is true.
+func (dm *dumper) visit(el ebml.Element, depth int, parentShown bool) error {
+	shown := dm.show(el, depth, parentShown)
+	if el.Schema.Type == ebml.TypeMaster {
+		if shown {
+			dm.print(el, depth, nil, false)
+		}
+		if dm.maxDepth >= 0 && depth >= dm.maxDepth || !shown && !dm.onPath(el) {
+			return dm.d.Skip(el)
+		}
+		return dm.d.DecodeChildren(el, func(child ebml.Element) error {
+			return dm.visit(child, depth+1, shown)
+		})
+	}
+	if !shown {
+		return dm.d.Skip(el)
+	}
+	if el.ID == ebml.IDVoid {
+		dm.print(el, depth, nil, false)
+		return dm.d.Skip(el)
+	}
+	val, ok, err := dm.read(el)
+	if err != nil {
+		return err
+	}
+	dm.print(el, depth, val, ok)
+	return nil
+}
+
+// show reports whether el at depth is printed. Every descendant of a
+// printed element matches the path filters.
+func (dm *dumper) show(el ebml.Element, depth int, parentShown bool) bool {
+	if dm.maxDepth >= 0 && depth > dm.maxDepth || el.ID == ebml.IDVoid && !dm.showVoid {
+		return false
+	}
+	if parentShown {
+		return true
+	}
+	for _, p := range dm.paths {
+		if el.Schema.Path == p || strings.HasPrefix(el.Schema.Path, p+`\`) {
+			return true
+		}
+	}
+	return false
+}
+
+// onPath reports whether a path filter selects a descendant of el.
+func (dm *dumper) onPath(el ebml.Element) bool {
+	for _, p := range dm.paths {
+		if el.Schema.Path != "" && strings.HasPrefix(p, el.Schema.Path+`\`) {
+			return true
+		}
+	}
+	return false
+}
+
+// read reads the value of the leaf element el. It reports false when the
+// value is not read.
+func (dm *dumper) read(el ebml.Element) (any, bool, error) {
+	var val any
+	var err error
+	switch el.Schema.Type {
+	case ebml.TypeInteger:
+		val, err = dm.d.ReadInteger(el)
+	case ebml.TypeUinteger:
+		val, err = dm.d.ReadUinteger(el)
+	case ebml.TypeFloat:
+		val, err = dm.d.ReadFloat(el)
+	case ebml.TypeString, ebml.TypeUTF8:
+		val, err = dm.d.ReadString(el)
+	case ebml.TypeDate:
+		val, err = dm.d.ReadDate(el)
+	default:
+		if el.DataSize > maxBinaryRead {
+			return nil, false, dm.d.Skip(el)
+		}
+		val, err = dm.d.ReadBinary(el)
+	}
+	return val, err == nil, err
+}
+
+// print writes the line of el. The value is printed when ok is true.
+func (dm *dumper) print(el ebml.Element, depth int, val any, ok bool) {
+	size := "unknown"
+	if el.DataSize != -1 {
+		size = strconv.FormatInt(el.DataSize, 10)
+	}
+	name := el.Schema.Name
+	if el.Schema.Name == ebml.UnknownSchema.Name {
+		name = "?"
+	}
+	fmt.Fprintf(dm.w, "%10d %4d %10s  %s%s [%v]", el.Offset, el.HeaderSize, size, strings.Repeat("  ", depth), name, el.ID)
+	if ok {
+		fmt.Fprintf(dm.w, ": %s", formatValue(val))
+	}
+	fmt.Fprintln(dm.w)
+}
+
+// formatValue formats a decoded value. Binary values are printed in
+// hexadecimal, truncated to maxBinaryDump octets.
+func formatValue(val any) string {
+	switch v := val.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []byte:
+		if len(v) > maxBinaryDump {
+			return hex.EncodeToString(v[:maxBinaryDump]) + "..."
+		}
+		return hex.EncodeToString(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(val)
+}
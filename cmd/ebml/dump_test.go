@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/ebml"
+	"github.com/coding-socks/ebml/schema"
+)
+
+var testSchema = filepath.Join("..", "..", "testdata", "test.xml")
+
+var (
+	testIDTest      schema.ElementID = 0x18538067
+	testIDInfo      schema.ElementID = 0x1549A966
+	testIDTitle     schema.ElementID = 0x7BA9
+	testIDDateUTC   schema.ElementID = 0x4461
+	testIDCluster   schema.ElementID = 0x1F43B675
+	testIDTimestamp schema.ElementID = 0xE7
+	testIDPayload   schema.ElementID = 0xA3
+)
+
+// writeTestFile writes a document of the test DocType and returns its name.
+func writeTestFile(t *testing.T) string {
+	t.Helper()
+	if err := (schemaFlag{}).Set(testSchema); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := ebml.NewEncoder(&buf)
+	if err := enc.EncodeHeader(&ebml.EBML{EBMLVersion: 1, EBMLReadVersion: 1, DocType: "test", DocTypeVersion: 1, DocTypeReadVersion: 1, EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8}); err != nil {
+		t.Fatal(err)
+	}
+	steps := []func() error{
+		func() error { return enc.StartMaster(testIDTest, ebml.UnknownSize) },
+		func() error { return enc.StartMaster(testIDInfo, ebml.AutoSize) },
+		func() error { return enc.WriteString(testIDTitle, "title") },
+		func() error { return enc.WriteDate(testIDDateUTC, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) },
+		enc.EndMaster,
+		func() error { return enc.WriteVoid(4) },
+		func() error { return enc.StartMaster(testIDCluster, ebml.AutoSize) },
+		func() error { return enc.WriteUinteger(testIDTimestamp, 42) },
+		func() error { return enc.WriteBinary(testIDPayload, bytes.Repeat([]byte{0xab}, 20)) },
+		enc.EndMaster,
+		enc.EndMaster,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	name := filepath.Join(t.TempDir(), "test.ebml")
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func runTestDump(t *testing.T, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := runDump(args, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestDump(t *testing.T) {
+	name := writeTestFile(t)
+	got := runTestDump(t, name)
+	for _, want := range []string{
+		"         0    5         31  EBML [0x1a45dfa3]\n",
+		`    DocType [0x4282]: "test"`,
+		" unknown  Test [0x18538067]\n",
+		`    Title [0x7ba9]: "title"`,
+		"    DateUTC [0x4461]: 2020-01-02T03:04:05Z\n",
+		"    Timestamp [0xe7]: 42\n",
+		"    Payload [0xa3]: abababababababababababababababab...\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("dump does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Void") {
+		t.Errorf("dump contains Void:\n%s", got)
+	}
+}
+
+func TestDump_flags(t *testing.T) {
+	name := writeTestFile(t)
+	tests := []struct {
+		args    []string
+		want    []string
+		notWant []string
+	}{
+		{
+			args:    []string{"-max-depth", "1"},
+			want:    []string{"  Info [", "  Cluster ["},
+			notWant: []string{"Title", "Timestamp"},
+		},
+		{
+			args:    []string{"-path", `\Test\Cluster`},
+			want:    []string{"Cluster [", "Timestamp ["},
+			notWant: []string{"EBML", "Info", "Title", "Test ["},
+		},
+		{
+			args: []string{"-show-void"},
+			want: []string{"  Void [0xec]\n"},
+		},
+	}
+	for _, tt := range tests {
+		got := runTestDump(t, append(tt.args, name)...)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("dump %v does not contain %q:\n%s", tt.args, want, got)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(got, notWant) {
+				t.Errorf("dump %v contains %q:\n%s", tt.args, notWant, got)
+			}
+		}
+	}
+}
+
+func TestDump_unknownDocType(t *testing.T) {
+	b := []byte("\x1a\x45\xdf\xa3\x8a\x42\x82\x87unknown\x40\x01\x82\x01\x02")
+	name := filepath.Join(t.TempDir(), "unknown.ebml")
+	if err := os.WriteFile(name, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got := runTestDump(t, name)
+	if want := "  ? [0x4001]: 0102\n"; !strings.Contains(got, want) {
+		t.Errorf("dump does not contain %q:\n%s", want, got)
+	}
+}
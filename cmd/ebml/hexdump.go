@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/coding-socks/ebml"
+	"github.com/coding-socks/ebml/ebmltext"
+	"github.com/coding-socks/ebml/schema"
+)
+
+func runHexdump(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("hexdump", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ebml hexdump [flags] [file]")
+		fs.PrintDefaults()
+	}
+	hd := hexdumper{}
+	fs.Int64Var(&hd.maxData, "max-data", 64, "print at most `n` octets of data per element; -1 prints all")
+	fs.Var(schemaFlag{}, "schema", "register the EBML schema in `file` (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	r, err := openInput(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	bw := bufio.NewWriter(stdout)
+	hd.w = bw
+	hd.r = ebmltext.NewDecoder(r)
+	err = hd.dump()
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// hexdumpWidth is the number of octets printed per line.
+const hexdumpWidth = 16
+
+// A hexdumper prints the octets of a document with the Element IDs, the
+// Element Data Sizes and the Element Data of each element annotated.
+//
+// It reads the document with the parsers of ebmltext, like a Decoder,
+// but it continues after the octets which cannot be parsed.
+type hexdumper struct {
+	w       io.Writer
+	r       *ebmltext.Decoder
+	def     *ebml.Def
+	maxData int64
+
+	// header holds the values of the EBML Header needed to read the
+	// EBML Body.
+	header map[schema.ElementID]any
+
+	// garbage holds the octets skipped since garbageErr, up to maxData.
+	garbage    []byte
+	garbageAt  int64
+	garbageLen int64
+	garbageErr error
+}
+
+func (hd *hexdumper) dump() error {
+	hd.def = ebml.HeaderDef
+	err := hd.children(ebml.RootEl, -1, 0)
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+// children prints the elements inside parent, which ends at the input
+// offset end or at an element which does not belong to it when end is -1.
+func (hd *hexdumper) children(parent ebml.Element, end int64, depth int) error {
+	for {
+		offset := hd.r.InputOffset()
+		if end != -1 && offset >= end {
+			return hd.flushGarbage(depth)
+		}
+		el, err := hd.peekHeader()
+		if err == io.EOF {
+			if err := hd.flushGarbage(depth); err != nil {
+				return err
+			}
+			if end != -1 {
+				hd.line(offset, nil, depth, fmt.Sprintf("error: unexpected EOF, %d octets of %s missing", end-offset, hd.name(parent)))
+			}
+			return io.EOF
+		}
+		if err != nil {
+			if err := hd.skipGarbage(err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := hd.flushGarbage(depth); err != nil {
+			return err
+		}
+		if end == -1 && parent.Schema.Path != ebml.RootEl.Schema.Path && !isChild(parent, el) {
+			return nil
+		}
+		if err := hd.element(el, end, depth); err != nil {
+			return err
+		}
+	}
+}
+
+// isChild reports whether el belongs to the master element parent with an
+// unknown data size, like the Decoder does.
+func isChild(parent, el ebml.Element) bool {
+	if el.ID == ebml.IDCRC32 || el.ID == ebml.IDVoid {
+		return true
+	}
+	return strings.HasPrefix(el.Schema.Path, parent.Schema.Path) && len(el.Schema.Path) != len(parent.Schema.Path)
+}
+
+// peekHeader parses the header of the next element without consuming it.
+func (hd *hexdumper) peekHeader() (ebml.Element, error) {
+	el := ebml.Element{Offset: hd.r.InputOffset()}
+	b := hd.r.Peek(int(hd.r.MaxIDLength + hd.r.MaxSizeLength))
+	if len(b) == 0 {
+		return el, io.EOF
+	}
+	id, w, err := ebmltext.ParseElementID(b, hd.r.MaxIDLength)
+	if err != nil {
+		return el, fmt.Errorf("invalid Element ID: %w", err)
+	}
+	ds, sw, err := ebmltext.ParseElementDataSize(b[w:], hd.r.MaxSizeLength)
+	if err != nil {
+		return el, fmt.Errorf("invalid Element Data Size of %v: %w", id, err)
+	}
+	el.ID, el.DataSize, el.HeaderSize = id, ds, w+sw
+	el.Schema = ebml.UnknownSchema
+	if hd.def != nil {
+		el.Schema, _ = hd.def.Get(id)
+	}
+	return el, nil
+}
+
+// element prints el inside a parent which ends at end.
+func (hd *hexdumper) element(el ebml.Element, end int64, depth int) error {
+	head := make([]byte, el.HeaderSize)
+	if _, err := io.ReadFull(hd.r, head); err != nil {
+		return err
+	}
+	idWidth := (bits.Len64(uint64(el.ID)) + 7) / 8
+	sizeWidth := el.HeaderSize - idWidth
+	hd.line(el.Offset, head[:idWidth], depth, fmt.Sprintf("%s id %v, width %d, %s", hd.name(el), el.ID, idWidth, vintBits(head[0], idWidth)))
+	size := "unknown"
+	if el.DataSize != -1 {
+		size = strconv.FormatInt(el.DataSize, 10)
+	}
+	hd.line(el.Offset+int64(idWidth), head[idWidth:], depth, fmt.Sprintf("  size %s, width %d, %s", size, sizeWidth, vintBits(head[idWidth], sizeWidth)))
+
+	dataEnd := int64(-1)
+	if el.DataSize != -1 {
+		dataEnd = el.Offset + int64(el.HeaderSize) + el.DataSize
+		if end != -1 && dataEnd > end {
+			hd.line(el.Offset+int64(el.HeaderSize), nil, depth, fmt.Sprintf("  error: the data overflows the parent by %d octets", dataEnd-end))
+			dataEnd = end
+		}
+	}
+	if el.Schema.Type == ebml.TypeMaster {
+		if el.ID == ebml.IDEBML {
+			hd.def = ebml.HeaderDef
+			hd.header = make(map[schema.ElementID]any)
+		}
+		err := hd.children(el, dataEnd, depth+1)
+		if el.ID == ebml.IDEBML && err == nil {
+			hd.useHeader()
+		}
+		return err
+	}
+	if el.DataSize == -1 {
+		hd.line(el.Offset+int64(el.HeaderSize), nil, depth, fmt.Sprintf("  error: unknown data size is not allowed for %s", hd.name(el)))
+		return nil
+	}
+	return hd.data(el, dataEnd-el.Offset-int64(el.HeaderSize), depth)
+}
+
+// data prints the n octets of data of the leaf element el.
+func (hd *hexdumper) data(el ebml.Element, n int64, depth int) error {
+	offset := el.Offset + int64(el.HeaderSize)
+	shown := n
+	if hd.maxData >= 0 {
+		shown = min(n, hd.maxData)
+	}
+	b := make([]byte, shown)
+	m, err := io.ReadFull(hd.r, b)
+	b = b[:m]
+	note := fmt.Sprintf("  data, %d octets", n)
+	if err == nil && shown == n {
+		if val, verr := hexValue(el, b); verr != nil {
+			note = "  data: error: " + verr.Error()
+		} else if val != nil {
+			note = "  data: " + formatValue(val)
+			if hd.header != nil && hd.def == ebml.HeaderDef {
+				hd.header[el.ID] = val
+			}
+		}
+	}
+	for i := 0; i < len(b) || i == 0; i += hexdumpWidth {
+		if len(b) == 0 {
+			hd.line(offset, nil, depth, note)
+			break
+		}
+		hd.line(offset+int64(i), b[i:min(i+hexdumpWidth, len(b))], depth, note)
+		note = ""
+	}
+	if err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			hd.line(offset+int64(m), nil, depth, fmt.Sprintf("  error: unexpected EOF, %d octets missing", n-int64(m)))
+			return io.EOF
+		}
+		return err
+	}
+	if rest := n - shown; rest > 0 {
+		hd.line(offset+shown, nil, depth, fmt.Sprintf("  ... %d more octets", rest))
+		m, err := io.CopyN(io.Discard, hd.r, rest)
+		if err == io.EOF {
+			hd.line(offset+shown+m, nil, depth, fmt.Sprintf("  error: unexpected EOF, %d octets missing", rest-m))
+		}
+		return err
+	}
+	return nil
+}
+
+// hexValue decodes the data b of el. It returns nil for binary data.
+func hexValue(el ebml.Element, b []byte) (any, error) {
+	switch el.Schema.Type {
+	case ebml.TypeInteger:
+		return ebmltext.Int(b)
+	case ebml.TypeUinteger:
+		return ebmltext.Uint(b)
+	case ebml.TypeFloat:
+		return ebmltext.Float(b)
+	case ebml.TypeString, ebml.TypeUTF8:
+		return ebmltext.String(b)
+	case ebml.TypeDate:
+		return ebmltext.Date(b)
+	}
+	return nil, nil
+}
+
+// useHeader switches to the schema and the lengths of the EBML Body.
+func (hd *hexdumper) useHeader() {
+	docType, _ := hd.header[ebml.IDDocType].(string)
+	for id, r := range map[schema.ElementID]*uint{
+		ebml.IDEBMLMaxIDLength:   &hd.r.MaxIDLength,
+		ebml.IDEBMLMaxSizeLength: &hd.r.MaxSizeLength,
+	} {
+		if v, ok := hd.header[id].(uint64); ok && v >= 1 && v <= 8 {
+			*r = uint(v)
+		}
+	}
+	hd.header = nil
+	def, err := ebml.Definition(strings.TrimRight(docType, "\x00"))
+	if err != nil {
+		log.Printf("%v; the EBML Body is printed with raw IDs", err)
+	}
+	hd.def = def
+}
+
+// skipGarbage consumes one octet which cannot be parsed because of err.
+func (hd *hexdumper) skipGarbage(err error) error {
+	if hd.garbageLen == 0 {
+		hd.garbageAt, hd.garbageErr = hd.r.InputOffset(), err
+	}
+	var b [1]byte
+	if _, err := io.ReadFull(hd.r, b[:]); err != nil {
+		return err
+	}
+	if hd.maxData < 0 || int64(len(hd.garbage)) < hd.maxData {
+		hd.garbage = append(hd.garbage, b[0])
+	}
+	hd.garbageLen++
+	return nil
+}
+
+// flushGarbage prints the octets skipped since the last element.
+func (hd *hexdumper) flushGarbage(depth int) error {
+	if hd.garbageLen == 0 {
+		return nil
+	}
+	note := "error: " + hd.garbageErr.Error()
+	for i := 0; i < len(hd.garbage); i += hexdumpWidth {
+		hd.line(hd.garbageAt+int64(i), hd.garbage[i:min(i+hexdumpWidth, len(hd.garbage))], depth, note)
+		note = ""
+	}
+	if rest := hd.garbageLen - int64(len(hd.garbage)); rest > 0 {
+		hd.line(hd.garbageAt+int64(len(hd.garbage)), nil, depth, fmt.Sprintf("... %d more octets skipped", rest))
+	}
+	hd.garbage, hd.garbageLen, hd.garbageErr = hd.garbage[:0], 0, nil
+	return nil
+}
+
+// line prints the octets b at offset with a note indented by depth.
+func (hd *hexdumper) line(offset int64, b []byte, depth int, note string) {
+	hexs := make([]string, len(b))
+	for i, c := range b {
+		hexs[i] = fmt.Sprintf("%02x", c)
+	}
+	fmt.Fprintf(hd.w, "%08x  %-*s  %s%s\n", offset, hexdumpWidth*3-1, strings.Join(hexs, " "), strings.Repeat("  ", depth), note)
+}
+
+func (hd *hexdumper) name(el ebml.Element) string {
+	if el.Schema.Name == ebml.UnknownSchema.Name || el.Schema.Name == "" {
+		return "?"
+	}
+	return el.Schema.Name
+}
+
+// vintBits formats the first octet b of a VINT of width w in binary, with
+// the VINT_WIDTH and the VINT_MARKER separated from the VINT_DATA.
+func vintBits(b byte, w int) string {
+	s := fmt.Sprintf("%08b", b)
+	if w > 8 {
+		return s
+	}
+	return s[:w] + "|" + s[w:]
+}
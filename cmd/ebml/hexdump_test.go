@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRunHexdump(t *testing.T) {
+	name := writeTestFile(t)
+	var out bytes.Buffer
+	if err := runHexdump([]string{"-max-data", "8", name}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"00000000  1a 45 df a3                                      EBML id 0x1a45dfa3, width 4, 0001|1010\n",
+		"00000028  ff                                                 size unknown, width 1, 1|1111111\n",
+		"00000031  74 69 74 6c 65                                         data: \"title\"\n",
+		"0000004f  ab ab ab ab ab ab ab ab                                data, 20 octets\n",
+		"00000057                                                         ... 12 more octets\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("runHexdump() = %s\nwant line %q", out.Bytes(), want)
+		}
+	}
+}
+
+func TestRunHexdump_damaged(t *testing.T) {
+	name := writeTestFile(t)
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[46] = 0 // first octet of the Title ID
+	if err := os.WriteFile(name, b[:len(b)-5], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := runHexdump([]string{name}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"0000002e  00                                                   error: invalid Element ID: ebmltext: invalid VINT_WIDTH\n",
+		"0000002f  a9                                                   ? id 0xa9, width 1, 1|0101001\n",
+		"00000036  44 61                                                DateUTC id 0x4461, width 2, 01|000100\n",
+		"0000005e                                                         error: unexpected EOF, 5 octets missing\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("runHexdump() = %s\nwant line %q", out.Bytes(), want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/coding-socks/ebml"
+)
+
+func runJSON(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("json", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ebml json [flags] [file]")
+		fs.PrintDefaults()
+	}
+	reverse := fs.Bool("import", false, "convert JSON to EBML instead")
+	fs.Var(schemaFlag{}, "schema", "register the EBML schema in `file` (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	r, err := openInput(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if *reverse {
+		nodes, err := ebml.DecodeJSON(r)
+		if err != nil {
+			return err
+		}
+		return ebml.NewEncoder(stdout).EncodeDocument(nodes)
+	}
+	nodes, err := ebml.NewDecoder(r).DecodeDocument()
+	if err != nil {
+		return err
+	}
+	return ebml.EncodeJSON(stdout, nodes)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	name := writeTestFile(t)
+	var js bytes.Buffer
+	if err := runJSON([]string{name}, &js); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(js.Bytes(), []byte(`"Title": "title"`)) {
+		t.Errorf("json does not contain the Title:\n%s", js.Bytes())
+	}
+
+	jsName := filepath.Join(t.TempDir(), "test.json")
+	if err := os.WriteFile(jsName, js.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var doc bytes.Buffer
+	if err := runJSON([]string{"-import", jsName}, &doc); err != nil {
+		t.Fatal(err)
+	}
+	docName := filepath.Join(t.TempDir(), "test.ebml")
+	if err := os.WriteFile(docName, doc.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var again bytes.Buffer
+	if err := runJSON([]string{docName}, &again); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != js.String() {
+		t.Errorf("json after import =\n%s\nwant\n%s", again.Bytes(), js.Bytes())
+	}
+}
@@ -0,0 +1,128 @@
+// Command ebml inspects EBML documents.
+//
+// Usage:
+//
+//	ebml <command> [flags] [file]
+//
+// The commands are:
+//
+//	diff      compare two documents element by element
+//	dump      print the elements of a document as an annotated tree
+//	hexdump   print the octets of a document with annotated headers
+//	json      convert a document to JSON, or JSON to a document
+//	schemadoc render the reference documentation of a schema
+//	validate  check a document against the schema of its DocType
+//
+// The document is read from the standard input when file is omitted or
+// is "-". Only the EBML Header is known by default; the schema of other
+// document types is registered with the -schema flag:
+//
+//	ebml dump -schema matroska.xml video.mkv
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/coding-socks/ebml"
+	"github.com/coding-socks/ebml/schema"
+)
+
+type command struct {
+	run   func(args []string, stdout io.Writer) error
+	short string
+}
+
+var commands = map[string]command{
+	"diff":      {runDiff, "compare two documents element by element"},
+	"dump":      {runDump, "print the elements of a document as an annotated tree"},
+	"hexdump":   {runHexdump, "print the octets of a document with annotated headers"},
+	"json":      {runJSON, "convert a document to JSON, or JSON to a document"},
+	"schemadoc": {runSchemaDoc, "render the reference documentation of a schema"},
+	"validate":  {runValidate, "check a document against the schema of its DocType"},
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ebml: ")
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		log.Printf("unknown command %q", os.Args[1])
+		usage()
+	}
+	if err := cmd.run(os.Args[2:], os.Stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: ebml <command> [flags] [file]")
+	fmt.Fprintln(os.Stderr, "\nThe commands are:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\t%-9s %s\n", name, commands[name].short)
+	}
+	os.Exit(2)
+}
+
+// schemaFlag registers the EBML schema in the file given to the flag.
+type schemaFlag struct{}
+
+func (schemaFlag) String() string { return "" }
+
+func (schemaFlag) Set(name string) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	var s schema.Schema
+	if err := xml.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if _, err := ebml.Definition(s.DocType); err == nil {
+		return nil // registered by an earlier flag
+	}
+	if _, err := ebml.NewDef(s); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	ebml.Register(s.DocType, s)
+	return nil
+}
+
+// stringsFlag collects the values of a repeated flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return fmt.Sprint(*f) }
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// openInput opens the file named by the only argument in args, or
+// returns the standard input.
+func openInput(args []string) (io.ReadCloser, error) {
+	switch {
+	case len(args) > 1:
+		return nil, errors.New("too many arguments")
+	case len(args) == 0 || args[0] == "-":
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(args[0])
+}
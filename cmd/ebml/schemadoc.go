@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/coding-socks/ebml"
+	"github.com/coding-socks/ebml/schema"
+)
+
+func runSchemaDoc(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("schemadoc", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ebml schemadoc [flags] file|doctype")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "markdown", "output `format`: markdown or html")
+	lang := fs.String("lang", "en", "print the documentation in `language`; empty prints all")
+	fs.Var(schemaFlag{}, "schema", "register the EBML schema in `file` (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	doc, err := loadSchemaDoc(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	doc.lang = *lang
+	w := bufio.NewWriter(stdout)
+	switch *format {
+	case "markdown", "md":
+		err = markdownTemplate.Execute(w, doc)
+	case "html":
+		err = htmlTemplate.Execute(w, doc)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// A schemaDoc is a schema prepared for the documentation templates.
+type schemaDoc struct {
+	DocType string
+	Version int
+	// Tree holds the elements below the root of the paths, and Global
+	// the elements which can appear at any level.
+	Tree   []*docElement
+	Global []*docElement
+
+	lang string
+}
+
+// A docElement is an element of a schemaDoc with its children.
+type docElement struct {
+	schema.Element
+	// Title is the name of the element. It is prefixed with the level
+	// range of a global element, like "(1-) CRC-32".
+	Title    string
+	Anchor   string
+	Depth    int
+	Children []*docElement
+	doc      *schemaDoc
+}
+
+// A docSection is documentation of an element with a purpose other than
+// the definition.
+type docSection struct {
+	Title      string
+	Paragraphs []string
+}
+
+// loadSchemaDoc reads the schema in the file name, or the schema of the
+// registered document type name.
+func loadSchemaDoc(name string) (*schemaDoc, error) {
+	var s schema.Schema
+	if b, err := os.ReadFile(name); err == nil {
+		if err := xml.Unmarshal(b, &s); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	} else {
+		def := ebml.HeaderDef
+		if name != "ebml" {
+			if def, err = ebml.Definition(name); err != nil {
+				return nil, fmt.Errorf("%s is neither a schema file nor a registered document type", name)
+			}
+		}
+		s.DocType = name
+		// The elements of a Def have no order, so they are sorted by path.
+		s.Elements = slices.SortedFunc(def.All(), func(a, b schema.Element) int {
+			return cmp.Compare(a.Path, b.Path)
+		})
+	}
+	doc := &schemaDoc{DocType: s.DocType, Version: s.Version}
+	byPath := make(map[string]*docElement)
+	for _, el := range s.Elements {
+		parent, name, global := splitSchemaPath(el.Path)
+		de := &docElement{Element: el, Title: name, Anchor: schemaAnchor(el.Path), doc: doc}
+		if global != "" {
+			de.Title = "(" + global + ") " + name
+		}
+		byPath[el.Path] = de
+		switch p := byPath[parent]; {
+		case global != "":
+			doc.Global = append(doc.Global, de)
+		case p != nil:
+			de.Depth = p.Depth + 1
+			p.Children = append(p.Children, de)
+		default:
+			doc.Tree = append(doc.Tree, de)
+		}
+	}
+	return doc, nil
+}
+
+// splitSchemaPath splits an EBML path into the path of the parent and the
+// name of the element. For a global element, it also returns the range of
+// levels like "1-".
+func splitSchemaPath(path string) (parent, name, global string) {
+	i := strings.LastIndex(path, `\`)
+	if strings.HasPrefix(path[i+1:], ")") {
+		// The name follows a global placeholder like \(1-\).
+		j := strings.LastIndex(path[:i], `\(`)
+		return path[:j], path[i+2:], path[j+2 : i]
+	}
+	// A recursive element is marked with a plus sign.
+	return path[:i], strings.TrimPrefix(path[i+1:], "+"), ""
+}
+
+// schemaAnchor returns an HTML id for the element at path.
+func schemaAnchor(path string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(path) {
+		if 'a' <= r && r <= 'z' || '0' <= r && r <= '9' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// All returns the elements of the tree depth first, followed by the
+// global elements.
+func (d *schemaDoc) All() []*docElement {
+	var all []*docElement
+	var walk func(els []*docElement)
+	walk = func(els []*docElement) {
+		for _, el := range els {
+			all = append(all, el)
+			walk(el.Children)
+		}
+	}
+	walk(d.Tree)
+	return append(all, d.Global...)
+}
+
+// Attributes returns the attributes of the element which are printed in
+// its table, as name and value pairs.
+func (e *docElement) Attributes() [][2]string {
+	attrs := [][2]string{
+		{"path", e.Path},
+		{"id", fmt.Sprintf("0x%X", uint64(e.ID))},
+		{"type", e.Type},
+		{"minOccurs", strconv.Itoa(e.MinOccurs)},
+	}
+	if e.MaxOccurs.Unbounded() {
+		attrs = append(attrs, [2]string{"maxOccurs", "unbounded"})
+	} else {
+		attrs = append(attrs, [2]string{"maxOccurs", strconv.Itoa(e.MaxOccurs.Val())})
+	}
+	for _, a := range [][2]string{{"range", e.Range}, {"length", e.Length}} {
+		if a[1] != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	if e.Default != nil {
+		attrs = append(attrs, [2]string{"default", *e.Default})
+	}
+	attrs = append(attrs, [2]string{"minver", strconv.Itoa(e.MinVer)})
+	if e.MaxVer != 0 {
+		attrs = append(attrs, [2]string{"maxver", strconv.Itoa(e.MaxVer)})
+	}
+	for _, a := range []struct {
+		name string
+		set  bool
+	}{{"unknownsizeallowed", e.UnknownSizeAllowed}, {"recursive", e.Recursive}, {"recurring", e.Recurring}} {
+		if a.set {
+			attrs = append(attrs, [2]string{a.name, "true"})
+		}
+	}
+	return attrs
+}
+
+// Docs returns the paragraphs of the documentation of the element with
+// the given purpose.
+func (e *docElement) Docs(purpose string) []string {
+	return docParagraphs(e.Documentation, purpose, e.doc.lang)
+}
+
+// Notes returns the implementation notes of the element as attribute and
+// text pairs.
+func (e *docElement) Notes() [][2]string {
+	var notes [][2]string
+	for _, n := range e.ImplementationNote {
+		notes = append(notes, [2]string{n.NoteAttribute, strings.Join(strings.Fields(n.Content), " ")})
+	}
+	return notes
+}
+
+// Enums returns the enumeration of the element as value, label and
+// definition triples.
+func (e *docElement) Enums() [][3]string {
+	if e.Restriction == nil {
+		return nil
+	}
+	var enums [][3]string
+	for _, en := range e.Restriction.Enum {
+		def := strings.Join(docParagraphs(en.Documentation, schema.PurposeDefinition, e.doc.lang), " ")
+		enums = append(enums, [3]string{en.Value, en.Label, def})
+	}
+	return enums
+}
+
+// Sections returns the documentation of the element which is not part of
+// its definition.
+func (e *docElement) Sections() []docSection {
+	var sections []docSection
+	for _, p := range [][2]string{
+		{schema.PurposeRationale, "Rationale"},
+		{schema.PurposeUsageNotes, "Usage notes"},
+		{schema.PurposeReferences, "References"},
+	} {
+		if paras := e.Docs(p[0]); len(paras) > 0 {
+			sections = append(sections, docSection{Title: p[1], Paragraphs: paras})
+		}
+	}
+	return sections
+}
+
+// docParagraphs returns the paragraphs of docs with the given purpose and
+// language. Documentation without a purpose is a definition, and
+// documentation without a language matches any language.
+func docParagraphs(docs []schema.Documentation, purpose, lang string) []string {
+	var paras []string
+	for _, d := range docs {
+		if cmp.Or(d.Purpose, schema.PurposeDefinition) != purpose || lang != "" && d.Lang != "" && d.Lang != lang {
+			continue
+		}
+		for _, p := range strings.Split(strings.ReplaceAll(d.Content, "\r\n", "\n"), "\n\n") {
+			if p = strings.Join(strings.Fields(p), " "); p != "" {
+				paras = append(paras, p)
+			}
+		}
+	}
+	return paras
+}
+
+// markdownCell escapes s for a cell of a Markdown table.
+func markdownCell(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "<", "&lt;", "\n", " ").Replace(s)
+}
+
+var templateFuncs = map[string]any{
+	"cell":   markdownCell,
+	"repeat": strings.Repeat,
+	"title":  func(d *schemaDoc) string { return cmp.Or(d.DocType, "EBML") },
+}
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(templateFuncs).Parse(`# {{title .}} schema
+{{if .Version}}
+Version {{.Version}} of the {{.DocType}} schema.
+{{end}}
+## Element tree
+{{define "tree"}}{{range .}}
+{{repeat "  " .Depth}}- [{{.Title}}](#{{.Anchor}}){{template "tree" .Children}}{{end}}{{end}}
+{{- template "tree" .Tree}}
+{{- if .Global}}
+
+Global elements:
+{{range .Global}}
+- [{{.Title}}](#{{.Anchor}}){{end}}{{end}}
+
+## Elements
+{{range .All}}
+<a id="{{.Anchor}}"></a>
+### {{.Title}}
+{{range .Docs "definition"}}
+{{.}}
+{{end}}
+| Attribute | Value |
+| --- | --- |
+{{range .Attributes}}| {{index . 0}} | {{cell (index . 1)}} |
+{{end}}{{with .Enums}}
+| Value | Label | Definition |
+| --- | --- | --- |
+{{range .}}| {{cell (index . 0)}} | {{cell (index . 1)}} | {{cell (index . 2)}} |
+{{end}}{{end}}{{range .Sections}}
+{{.Title}}:
+{{range .Paragraphs}}
+{{.}}
+{{end}}{{end}}{{with .Notes}}
+Implementation notes:
+{{range .}}
+- {{with index . 0}}` + "`{{.}}`" + `: {{end}}{{index . 1}}{{end}}
+{{end}}{{end}}`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{title .}} schema</title>
+</head>
+<body>
+<h1>{{title .}} schema</h1>
+{{if .Version}}<p>Version {{.Version}} of the {{.DocType}} schema.</p>
+{{end}}<h2>Element tree</h2>
+{{define "tree"}}<ul>
+{{range .}}<li><a href="#{{.Anchor}}">{{.Title}}</a>{{with .Children}}
+{{template "tree" .}}{{end}}</li>
+{{end}}</ul>{{end}}{{template "tree" .Tree}}
+{{with .Global}}<p>Global elements:</p>
+{{template "tree" .}}
+{{end}}<h2>Elements</h2>
+{{range .All}}<h3 id="{{.Anchor}}">{{.Title}}</h3>
+{{range .Docs "definition"}}<p>{{.}}</p>
+{{end}}<table>
+<tr><th>Attribute</th><th>Value</th></tr>
+{{range .Attributes}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{with .Enums}}<table>
+<tr><th>Value</th><th>Label</th><th>Definition</th></tr>
+{{range .}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td><td>{{index . 2}}</td></tr>
+{{end}}</table>
+{{end}}{{range .Sections}}<h4>{{.Title}}</h4>
+{{range .Paragraphs}}<p>{{.}}</p>
+{{end}}{{end}}{{with .Notes}}<h4>Implementation notes</h4>
+<ul>
+{{range .}}<li>{{with index . 0}}<code>{{.}}</code>: {{end}}{{index . 1}}</li>
+{{end}}</ul>
+{{end}}{{end}}</body>
+</html>
+`))
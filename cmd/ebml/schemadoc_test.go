@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDocSchema = `<?xml version="1.0" encoding="utf-8"?>
+<EBMLSchema xmlns="urn:ietf:rfc:8794" docType="doc" version="2">
+    <element name="Doc" path="\Doc" id="0x1A000001" type="master">
+        <documentation lang="en" purpose="definition">The root.</documentation>
+    </element>
+    <element name="Mode" path="\Doc\Mode" id="0x4001" type="uinteger" range="0-1" default="0" maxOccurs="1" minver="2">
+        <documentation lang="en" purpose="definition">How the document
+        is read.
+
+        A second | paragraph.</documentation>
+        <documentation lang="fr" purpose="definition">Le mode.</documentation>
+        <documentation lang="en" purpose="rationale">Because.</documentation>
+        <implementation_note note_attribute="default">Readers use 0.</implementation_note>
+        <restriction>
+            <enum value="0" label="plain"><documentation lang="en" purpose="definition">Plain mode.</documentation></enum>
+            <enum value="1" label="fancy"/>
+        </restriction>
+    </element>
+    <element name="Chapter" path="\Doc\+Chapter" id="0x4002" type="master" recursive="1"/>
+    <element name="Stamp" path="\Doc\(1-\)Stamp" id="0x4003" type="date"/>
+</EBMLSchema>
+`
+
+func TestSchemaDoc(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "doc.xml")
+	if err := os.WriteFile(name, []byte(testDocSchema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var md bytes.Buffer
+	if err := runSchemaDoc([]string{name}, &md); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# doc schema\n\nVersion 2 of the doc schema.\n",
+		"- [Doc](#doc)\n  - [Mode](#doc-mode)\n  - [Chapter](#doc-chapter)\n",
+		"Global elements:\n\n- [(1-) Stamp](#doc-1-stamp)\n",
+		"<a id=\"doc-mode\"></a>\n### Mode\n\nHow the document is read.\n\nA second | paragraph.\n",
+		"| path | \\\\Doc\\\\Mode |\n| id | 0x4001 |\n",
+		"| range | 0-1 |\n| default | 0 |\n| minver | 2 |\n",
+		"| 0 | plain | Plain mode. |\n| 1 | fancy |  |\n",
+		"Rationale:\n\nBecause.\n",
+		"- `default`: Readers use 0.\n",
+		"| recursive | true |\n",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("markdown does not contain %q:\n%s", want, md.String())
+		}
+	}
+	if strings.Contains(md.String(), "Le mode.") {
+		t.Errorf("markdown contains documentation in another language")
+	}
+
+	var html bytes.Buffer
+	if err := runSchemaDoc([]string{"-format", "html", "-lang", "", name}, &html); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<h3 id="doc-mode">Mode</h3>`,
+		`<p>A second | paragraph.</p>`,
+		`<p>Le mode.</p>`,
+		`<tr><td>0</td><td>plain</td><td>Plain mode.</td></tr>`,
+		`<li><code>default</code>: Readers use 0.</li>`,
+	} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("html does not contain %q:\n%s", want, html.String())
+		}
+	}
+}
+
+func TestSchemaDoc_registered(t *testing.T) {
+	var md bytes.Buffer
+	if err := runSchemaDoc([]string{"ebml"}, &md); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md.String(), "  - [DocType](#ebml-doctype)\n") {
+		t.Errorf("markdown does not contain DocType:\n%s", md.String())
+	}
+	if err := runSchemaDoc([]string{"missing"}, &md); err == nil {
+		t.Error("runSchemaDoc(missing) error = nil")
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/coding-socks/ebml"
+)
+
+// errInvalid makes the validate command exit with a non-zero status
+// after it printed the report of an invalid document.
+var errInvalid = errors.New("document is invalid")
+
+func runValidate(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ebml validate [flags] [file]")
+		fs.PrintDefaults()
+	}
+	var opts ebml.ValidateOptions
+	fs.IntVar(&opts.MaxIssues, "max-issues", 0, "stop after `n` issues, 0 means no limit")
+	fs.BoolVar(&opts.SkipCRC, "skip-crc", false, "do not verify CRC-32 elements")
+	fs.Var(schemaFlag{}, "schema", "register the EBML schema in `file` (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	r, err := openInput(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	report, err := ebml.Validate(r, opts)
+	if err != nil {
+		return err
+	}
+	if report.Issues == nil {
+		report.Issues = []ebml.Issue{}
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	if !report.Valid {
+		return errInvalid
+	}
+	return nil
+}
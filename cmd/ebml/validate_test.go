@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/coding-socks/ebml"
+)
+
+func TestValidate(t *testing.T) {
+	name := writeTestFile(t)
+	var out bytes.Buffer
+	if err := runValidate([]string{name}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var report ebml.Report
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.DocType != "test" || len(report.Issues) != 0 {
+		t.Errorf("report = %s", out.Bytes())
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, append(b, 0xff, 0xff), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := runValidate([]string{name}, &out); !errors.Is(err, errInvalid) {
+		t.Fatalf("runValidate() error = %v, want errInvalid", err)
+	}
+	// The root element has an unknown size, so the garbage is inside it.
+	if !bytes.Contains(out.Bytes(), []byte(`"rule": "damaged"`)) {
+		t.Errorf("report does not contain the damaged data:\n%s", out.Bytes())
+	}
+}
@@ -0,0 +1,134 @@
+package ebml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the conformance suite")
+
+// TestConformance decodes and validates the documents of
+// testdata/conformance, each of which exercises a rule of RFC 8794, and
+// compares the results with the golden files next to them.
+//
+// A document is written in hexadecimal, where whitespace is ignored and
+// "#" starts a comment until the end of the line. Run the test with
+// -update to rewrite the golden files after an intended change.
+func TestConformance(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join("testdata", "conformance", "*.hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no conformance documents")
+	}
+	for _, name := range names {
+		t.Run(strings.TrimSuffix(filepath.Base(name), ".hex"), func(t *testing.T) {
+			src, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := parseConformanceHex(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := conformanceResult(doc)
+			golden := strings.TrimSuffix(name, ".hex") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("result differs from %s\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+// parseConformanceHex returns the octets written in src.
+func parseConformanceHex(src []byte) ([]byte, error) {
+	var digits strings.Builder
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		digits.WriteString(strings.Join(strings.Fields(line), ""))
+	}
+	return hex.DecodeString(digits.String())
+}
+
+// conformanceResult describes how doc is decoded by DecodeDocument, by
+// DecodeHeader and DecodeBody into a testDocument, and how it is validated.
+func conformanceResult(doc []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# DecodeDocument\n")
+	nodes, err := NewDecoder(bytes.NewReader(doc)).DecodeDocument()
+	writeConformanceNodes(&buf, nodes, 0)
+	writeConformanceError(&buf, err)
+
+	buf.WriteString("\n# DecodeHeader and DecodeBody\n")
+	d := NewDecoder(bytes.NewReader(doc))
+	h, err := d.DecodeHeader()
+	if h != nil {
+		fmt.Fprintf(&buf, "%+v\n", *h)
+	}
+	writeConformanceError(&buf, err)
+	if err == nil {
+		var body testDocument
+		err = d.DecodeBody(&body)
+		fmt.Fprintf(&buf, "%+v\n", body)
+		writeConformanceError(&buf, err)
+	}
+
+	buf.WriteString("\n# Validate\n")
+	report, err := Validate(bytes.NewReader(doc), ValidateOptions{})
+	fmt.Fprintf(&buf, "valid: %v\n", report.Valid)
+	for _, issue := range report.Issues {
+		fmt.Fprintln(&buf, issue)
+	}
+	writeConformanceError(&buf, err)
+	return buf.Bytes()
+}
+
+func writeConformanceNodes(w io.Writer, nodes []*Node, depth int) {
+	for _, n := range nodes {
+		name := n.Schema.Name
+		if name == UnknownSchema.Name {
+			name = n.ID.String()
+		}
+		fmt.Fprintf(w, "%s%s", strings.Repeat("  ", depth), name)
+		switch v := n.Value.(type) {
+		case nil:
+		case string:
+			fmt.Fprintf(w, " = %q", v)
+		case []byte:
+			fmt.Fprintf(w, " = 0x%x", v)
+		case time.Time:
+			fmt.Fprintf(w, " = %s", v.Format(time.RFC3339Nano))
+		default:
+			fmt.Fprintf(w, " = %v", v)
+		}
+		fmt.Fprintln(w)
+		writeConformanceNodes(w, n.Children, depth+1)
+	}
+}
+
+func writeConformanceError(w io.Writer, err error) {
+	if err != nil {
+		fmt.Fprintf(w, "error: %s\n", strings.ReplaceAll(err.Error(), "\n", "\n       "))
+	}
+}
@@ -1,11 +1,14 @@
 package ebml
 
 import (
+	"encoding"
 	"errors"
 	"fmt"
 	"github.com/coding-socks/ebml/ebmltext"
 	"github.com/coding-socks/ebml/schema"
 	"io"
+	"math"
+	"math/big"
 	"reflect"
 	"strconv"
 	"time"
@@ -14,17 +17,24 @@ import (
 // An DecodeTypeError describes an EBML value that was
 // not appropriate for a value of a specific Go type.
 type DecodeTypeError struct {
-	EBMLType string       // description of EBML type - "integer", "binary", "master"
-	Type     reflect.Type // type of Go value it could not be assigned to
-	Offset   int64        // error occurred after reading Offset bytes
-	Path     string       // the full path from root node to the field
+	EBMLType   string           // description of EBML type - "integer", "binary", "master"
+	Value      string           // the decoded value which did not fit, if any
+	Type       reflect.Type     // type of Go value it could not be assigned to
+	Offset     int64            // input offset of the element
+	ID         schema.ElementID // ID of the element
+	SchemaPath string           // path of the element in the schema
+	Path       string           // the full path from root node to the field
 }
 
 func (e *DecodeTypeError) Error() string {
+	what := e.EBMLType
+	if e.Value != "" {
+		what += " " + e.Value
+	}
 	if e.Path != "" {
-		return fmt.Sprintf("ebml: cannot unmarshal %s into Go struct field %s of type %s", e.EBMLType, e.Path, e.Type)
+		return fmt.Sprintf("ebml: cannot unmarshal %s at offset %d into Go struct field %s of type %s", what, e.Offset, e.Path, e.Type)
 	}
-	return fmt.Sprintf("ebml: cannot unmarshal %s into Go value of type %s", e.EBMLType, e.Type)
+	return fmt.Sprintf("ebml: cannot unmarshal %s at offset %d into Go value of type %s", what, e.Offset, e.Type)
 }
 
 func (e *DecodeTypeError) extendError(p string) {
@@ -52,11 +62,87 @@ func (e *InvalidDecodeError) Error() string {
 	return "ebml: Unmarshal(nil " + e.Type.String() + ")"
 }
 
-// ErrElementOverflow signals that an element signals a length
-// greater than the parent DataSize.
-var ErrElementOverflow = errors.New("ebml: element overflow")
+// A SyntaxError describes malformed EBML data, such as an Element ID or
+// an Element Data Size which is not a valid VINT.
+type SyntaxError struct {
+	Offset int64 // input offset where the error occurred
+	Err    error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("ebml: syntax error at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// An ElementError describes a problem with a well-formed element, such
+// as an element which does not fit into its parent or which cannot be
+// read completely.
+type ElementError struct {
+	Offset     int64            // input offset of the element
+	ID         schema.ElementID // ID of the element
+	SchemaPath string           // path of the element in the schema
+	Path       string           // the full path from root node to the field
+	Err        error
+}
+
+func newElementError(el Element, err error) *ElementError {
+	return &ElementError{Offset: el.Offset, ID: el.ID, SchemaPath: el.Schema.Path, Err: err}
+}
+
+func (e *ElementError) Error() string {
+	name := e.SchemaPath
+	if name == "" {
+		name = e.ID.String()
+	}
+	if e.Path != "" {
+		return fmt.Sprintf("ebml: element %s at offset %d (Go struct field %s): %v", name, e.Offset, e.Path, e.Err)
+	}
+	return fmt.Sprintf("ebml: element %s at offset %d: %v", name, e.Offset, e.Err)
+}
+
+func (e *ElementError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ElementError) extendError(p string) {
+	if e.Path == "" {
+		e.Path = p
+		return
+	}
+	e.Path = p + "." + e.Path
+}
+
+// extendFieldPath prepends p to the Go field path of err.
+func extendFieldPath(err error, p string) {
+	if e := (*DecodeTypeError)(nil); errors.As(err, &e) {
+		e.extendError(p)
+	}
+	if e := (*ElementError)(nil); errors.As(err, &e) {
+		e.extendError(p)
+	}
+}
+
+var (
+	// ErrElementOverflow signals that an element signals a length
+	// greater than the parent DataSize.
+	ErrElementOverflow = errors.New("ebml: element overflow")
+	// ErrUnexpectedElement signals an element which is not allowed
+	// at its position.
+	ErrUnexpectedElement = errors.New("ebml: unexpected element")
+	// ErrUnknownSizeNotAllowed signals an element with unknown data size
+	// which is not a master element, or which the schema does not allow
+	// to be of unknown size.
+	ErrUnknownSizeNotAllowed = errors.New("ebml: element is not allowed to be of unknown size")
+)
 
 // DecodeHeader decodes the document header.
+//
+// When the DocType is not registered, DecodeHeader returns the header
+// with an UnknownDocTypeError, and the elements of the EBML Body are read
+// with UnknownSchema.
 func (d *Decoder) DecodeHeader() (*EBML, error) {
 	for {
 		el, _, err := d.NextOf(RootEl, 0)
@@ -65,10 +151,10 @@ func (d *Decoder) DecodeHeader() (*EBML, error) {
 		}
 		switch el.ID {
 		default:
-			return nil, fmt.Errorf("ebml: unexpected element %v in root", el.ID)
+			return nil, newElementError(el, ErrUnexpectedElement)
 		case IDVoid:
-			if _, err := io.CopyN(io.Discard, d.r, el.DataSize); err != nil {
-				return nil, fmt.Errorf("ebml: could not skip Void element: %w", err)
+			if err := d.Skip(el); err != nil {
+				return nil, err
 			}
 			continue
 		case IDEBML:
@@ -80,13 +166,16 @@ func (d *Decoder) DecodeHeader() (*EBML, error) {
 			if err != nil {
 				return nil, err
 			}
-			d.def, err = Definition(h.DocType)
-			if err != nil {
-				return nil, err
-			}
 			d.r.MaxIDLength = h.EBMLMaxIDLength
 			d.r.MaxSizeLength = h.EBMLMaxSizeLength
-			return &h, err
+			def, err := Definition(h.DocType)
+			if err != nil {
+				// The EBML Body can still be read, with UnknownSchema
+				// for the elements of the unknown DocType.
+				return &h, err
+			}
+			d.def = def
+			return &h, nil
 		}
 	}
 }
@@ -97,19 +186,30 @@ func (d *Decoder) DecodeHeader() (*EBML, error) {
 func (d *Decoder) DecodeBody(v interface{}) error {
 	for {
 		el, _, err := d.NextOf(RootEl, 0)
+		if isDamage(err) {
+			if err := d.recover(d.r.InputOffset(), err); err != nil {
+				return errors.Join(err, d.skippedErrs)
+			}
+			continue
+		}
 		if err != nil {
 			return err
 		}
 		switch el.ID {
 		default:
-			return fmt.Errorf("ebml: unexpected element %v in root", el.ID)
+			return newElementError(el, ErrUnexpectedElement)
 		case IDVoid:
-			if _, err := io.CopyN(io.Discard, d.r, el.DataSize); err != nil {
-				return fmt.Errorf("ebml: could not skip Void element: %w", err)
+			if err := d.Skip(el); err != nil {
+				return err
 			}
 			continue
 		case d.def.Root.ID:
-			return d.Decode(el, v)
+			skipped := d.skippedErrs
+			err := d.Decode(el, v)
+			if skipped != nil {
+				err = errors.Join(err, skipped)
+			}
+			return err
 		}
 	}
 }
@@ -120,18 +220,63 @@ func (d *Decoder) SkipByte() error {
 	return err
 }
 
+// Skip discards the data of el. The children of a master element with
+// unknown data size are skipped one by one.
 func (d *Decoder) Skip(el Element) error {
-	_, err := io.CopyN(io.Discard, d.r, el.DataSize)
-	return err
+	if el.DataSize == -1 {
+		if el.Schema.Type != TypeMaster {
+			return newElementError(el, ErrUnknownSizeNotAllowed)
+		}
+		return d.DecodeChildren(el, d.Skip)
+	}
+	if _, err := io.CopyN(io.Discard, d.r, el.DataSize); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return newElementError(el, err)
+	}
+	return nil
 }
 
+// Decode reads the data of el and stores it in the value pointed to by v.
+//
+// The children of a master element are stored in the fields of a struct.
+// A field matches the elements with its name, which can be changed with
+// the "ebml" struct tag. The tag can also select an element by its ID in
+// hexadecimal notation, and it accepts the following options:
+//
+//	Title    string       `ebml:"Title"`                   // element named Title
+//	Version  uint         `ebml:"0x4286"`                  // element with ID 0x4286, or named Version
+//	Version  uint         `ebml:"id=0x4286"`               // same as above
+//	Private  uint         `ebml:"id=0x7373,type=uinteger"` // element missing from the schema
+//	Scale    uint         `ebml:",omitempty"`              // omitted by Encoder when empty
+//	Info     Info         `ebml:",inline"`                 // fields of Info are fields of the parent
+//	Extra    []RawElement `ebml:",unknown"`                // children without a field
+//	Internal int          `ebml:"-"`                       // ignored
+//
+// Fields are matched by the ID in their tag first, and then by name.
+// An element missing from the schema can only be decoded into a field
+// which selects it by ID and gives its type. Embedded structs are inlined
+// like fields with the inline option.
+//
+// Following RFC 8794, a field of an absent or empty element gets the
+// default value of the schema. A field of an absent master element which
+// is mandatory gets a value which holds the defaults of its children.
+// A field of an element which is present but cannot be decoded also gets
+// the default value, and Decode returns the error.
+// Embed Presence into a struct to tell which fields were present, or Meta
+// to also know the position and the sizes of their elements.
 func (d *Decoder) Decode(el Element, v interface{}) error {
 	val := reflect.ValueOf(v)
 	if val.Kind() != reflect.Ptr || val.IsNil() {
 		return &InvalidDecodeError{reflect.TypeOf(v)}
 	}
 	d.skippedErrs = nil
+	d.arena = d.arena[:0]
 	err := d.decodeSingle(el, val.Elem())
+	if err != nil && val.Elem().Kind() == reflect.Struct {
+		extendFieldPath(err, val.Elem().Type().Name())
+	}
 	if d.skippedErrs != nil {
 		err = errors.Join(err, d.skippedErrs)
 	}
@@ -142,21 +287,9 @@ var (
 	typeTime      = reflect.TypeOf(time.Time{})
 	typeDuration  = reflect.TypeOf(time.Duration(0))
 	typeElementID = reflect.TypeOf(schema.ElementID(0))
+	typeBigInt    = reflect.TypeOf(big.Int{})
 )
 
-func findField(val reflect.Value, tinfo *typeInfo, name string) (fieldv reflect.Value, found bool) {
-	for i := range tinfo.fields {
-		finfo := tinfo.fields[i]
-		if name != finfo.name {
-			continue
-		}
-		found = true
-		fieldv = val.Field(finfo.idx[0])
-		break
-	}
-	return
-}
-
 func (d *Decoder) decodeMaster(val reflect.Value, current Element) error {
 	// Load value from interface, but only if the result will be
 	// usefully addressable.
@@ -176,7 +309,7 @@ func (d *Decoder) decodeMaster(val reflect.Value, current Element) error {
 
 	switch v := val; v.Kind() {
 	default:
-		return errors.New("unknown master element type: " + val.Type().String())
+		return &DecodeTypeError{EBMLType: TypeMaster, Type: val.Type(), Offset: current.Offset, ID: current.ID, SchemaPath: current.Schema.Path}
 	case reflect.Slice:
 		// TODO: Consider checking max / min occurrence.
 		e := v.Type().Elem()
@@ -186,120 +319,170 @@ func (d *Decoder) decodeMaster(val reflect.Value, current Element) error {
 	case reflect.Struct:
 		// Everything is ok
 	}
-	typ := val.Type()
-	tinfo, ok := d.typeInfos[typ]
-	if !ok {
-		var err error
-		if tinfo, err = getTypeInfo(typ); err != nil {
-			return err
-		}
-		d.typeInfos[typ] = tinfo
+	if u, ok := val.Addr().Interface().(Unmarshaler); ok {
+		return u.DecodeEBML(d, current)
+	}
+	tinfo, err := getTypeInfo(val.Type())
+	if err != nil {
+		return err
+	}
+	if r := tinfo.recorder(val); r != nil {
+		r.reset()
 	}
+	seen := make([]bool, len(tinfo.fields))
+	err = d.decodeFields(val, tinfo, current, seen)
+	// The defaults are applied even after an error, like they were
+	// decoded before the first child.
+	if derr := d.applyDefaults(val, tinfo, current.Schema.Path, current.Offset, seen); err == nil {
+		err = derr
+	}
+	return err
+}
 
-	// Prepopulate the default values, they will be overwritten when defined.
-	for sel := range d.def.Fields(current.Schema.Path) {
-		if sel.Default == nil {
-			continue
+// decodeFields decodes the children of current into the fields of the
+// struct val, and marks the decoded fields in seen. The fields of children
+// skipped by the Visitor are marked too, as their elements are present.
+func (d *Decoder) decodeFields(val reflect.Value, tinfo *typeInfo, current Element, seen []bool) error {
+	fields := tinfo.fieldsByID(d.def)
+	r := tinfo.recorder(val)
+	onSkip := d.onSkip
+	defer func() { d.onSkip = onSkip }()
+	d.onSkip = func(parent, el Element) {
+		if parent.ID != current.ID || parent.Offset != current.Offset {
+			return
+		}
+		if finfo, found := fields[el.ID]; found {
+			seen[finfo.pos] = true
+			if r != nil {
+				r.record(finfo.goName, documentInfo(el))
+			}
 		}
-		fieldv, found := findField(val, tinfo, sel.Name)
+	}
+	return d.DecodeChildren(current, func(el Element) error {
+		finfo, found := fields[el.ID]
 		if !found {
-			continue
-		}
-		if fieldv.Kind() == reflect.Ptr {
-			if fieldv.IsNil() {
-				fieldv.Set(reflect.New(fieldv.Type().Elem()))
+			if el.DataSize == -1 {
+				return d.decodeFields(val, tinfo, el, seen)
 			}
-			fieldv = fieldv.Elem()
-		}
-		if v := fieldv; v.Kind() == reflect.Slice {
-			e := v.Type().Elem()
-			if !(sel.Type == TypeBinary && e.Kind() == reflect.Uint8) {
-				n := v.Len()
-				v.Set(reflect.Append(v, reflect.Zero(e)))
-				fieldv = v.Index(n)
+			if tinfo.unknown == nil {
+				return d.Skip(el)
 			}
-		}
-
-		if err := validateReflectType(fieldv, sel, 0); err != nil {
-			if e, ok := err.(*DecodeTypeError); ok {
-				e.extendError(sel.Name)
-				e.extendError(val.Type().Name())
+			fieldv, err := fieldByIndex(val, tinfo.unknown.idx)
+			if err != nil {
+				return newElementError(el, err)
 			}
-			return err
+			if r != nil {
+				r.record(tinfo.unknown.goName, documentInfo(el))
+			}
+			return d.decodeRaw(el, fieldv)
 		}
-		switch sel.Type {
-		case TypeInteger:
-			x, _ := strconv.ParseInt(*sel.Default, 10, 64)
-			fieldv.SetInt(x)
-		case TypeUinteger:
-			if fieldv.Type() == typeDuration {
-				x, _ := strconv.ParseInt(*sel.Default, 10, 64)
-				fieldv.SetInt(x)
-			} else {
-				x, _ := strconv.ParseUint(*sel.Default, 10, 64)
-				fieldv.SetUint(x)
+		if el.Schema.Name == UnknownSchema.Name {
+			sch, ok := finfo.schema(current.Schema.Path)
+			if !ok {
+				err := newElementError(el, fmt.Errorf("ebml: element is not defined by the schema, tag needs a type"))
+				extendFieldPath(err, finfo.goName)
+				return err
 			}
-		case TypeFloat:
-			x, _ := strconv.ParseFloat(*sel.Default, 64)
-			fieldv.SetFloat(x)
-		case TypeString:
-			fieldv.SetString(*sel.Default)
-		default:
-			return fmt.Errorf("default not supported: %s", sel.Type)
+			el.Schema = sch
+		}
+		fieldv, err := fieldByIndex(val, finfo.idx)
+		if err != nil {
+			return newElementError(el, err)
+		}
+		seen[finfo.pos] = true
+		if r != nil {
+			r.record(finfo.goName, documentInfo(el))
 		}
+		if err := d.decodeSingle(el, fieldv); err != nil {
+			extendFieldPath(err, finfo.goName)
+			return err
+		}
+		return nil
+	})
+}
+
+// decodeRaw appends el with its encoded data to the []RawElement val.
+func (d *Decoder) decodeRaw(el Element, val reflect.Value) error {
+	b, err := d.readAlloc(el, d.allocBinary)
+	if err != nil {
+		return err
 	}
+	val.Set(reflect.Append(val, reflect.ValueOf(RawElement{ID: el.ID, Data: b})))
+	return nil
+}
 
-	offset := int64(0)
+// DecodeChildren reads the children of the master element current and
+// calls f for each of them. The function f must consume the data of the
+// child, for example by calling Decode, Skip or one of the Read methods.
+//
+// DecodeChildren detects the end of current, recovers from damaged data
+// and shrinks children which overflow current.
+func (d *Decoder) DecodeChildren(current Element, f func(el Element) error) error {
+	d.ancestors = append(d.ancestors, current)
+	defer func() { d.ancestors = d.ancestors[:len(d.ancestors)-1] }()
+	start := d.r.InputOffset()
+	resynced := false
 	for {
-		el, n, err := d.NextOf(current, offset)
-		offset += int64(n)
-		if errors.Is(err, ErrInvalidVINTLength) {
-			_ = d.SkipByte()
-			offset += 1
+		offset := d.r.InputOffset() - start
+		el, _, err := d.NextOf(current, offset)
+		if isDamage(err) {
+			if err := d.recover(d.r.InputOffset(), err); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			resynced = true
 			continue
 		}
 		if err == io.EOF {
 			break
 		}
-		// detect element overflow early to pretend the element is smaller
-		if errors.Is(err, ErrElementOverflow) {
-			el.DataSize = current.DataSize - offset
-			// This can be skipped
-			d.skippedErrs = errors.Join(err, d.skippedErrs)
-		} else if err != nil {
+		if err != nil && !errors.Is(err, ErrElementOverflow) {
 			return err
 		}
-		if current.DataSize != -1 {
-			offset += el.DataSize
+		if resynced {
+			resynced = false
+			if !isChild(current, el) {
+				// The element found after damaged data belongs to an ancestor.
+				tmp := el
+				d.el, d.elVisited = &tmp, true
+				return nil
+			}
 		}
-		fieldv, found := findField(val, tinfo, el.Schema.Name)
-		if !found {
-			if el.DataSize != -1 {
-				if _, err := io.CopyN(io.Discard, d.r, el.DataSize); err != nil {
-					return fmt.Errorf("ebml: failed to skip element: %w", err)
-				}
-				continue
-			} else if el.Schema.Type == TypeMaster {
-				if err := d.decodeMaster(val, el); err != nil {
+		// detect element overflow early to pretend the element is smaller
+		if errors.Is(err, ErrElementOverflow) {
+			if el.Schema.Name == UnknownSchema.Name {
+				// An unknown element which does not fit is most likely garbage.
+				if err := d.recover(el.Offset, err); err != nil && err != io.EOF {
 					return err
 				}
+				resynced = true
 				continue
-			} else {
-				return errors.New("ebml: only a master element is allowed to be of unknown size")
 			}
+			// The header of el can already cross the end of current.
+			el.DataSize = max(current.DataSize-(d.r.InputOffset()-start), 0)
+			// This can be skipped
+			d.skippedErrs = errors.Join(d.skippedErrs, err)
 		}
-
-		if err := d.decodeSingle(el, fieldv); err != nil {
-			var e *DecodeTypeError
-			if errors.As(err, &e) {
-				e.extendError(val.Type().Name())
+		if el.DataSize == -1 && el.Schema.Type != TypeMaster {
+			err := newElementError(el, ErrUnknownSizeNotAllowed)
+			if el.Schema.Name != UnknownSchema.Name {
+				return err
+			}
+			if err := d.recover(el.Offset, err); err != nil && err != io.EOF {
+				return err
 			}
+			resynced = true
+			continue
+		}
+		if err := f(el); err != nil {
 			return err
 		}
 	}
 
-	if current.DataSize != -1 && offset < current.DataSize {
-		return io.ErrUnexpectedEOF
+	if offset := d.r.InputOffset() - start; current.DataSize != -1 && offset < current.DataSize {
+		return newElementError(current, io.ErrUnexpectedEOF)
 	}
 	return nil
 }
@@ -307,36 +490,41 @@ func (d *Decoder) decodeMaster(val reflect.Value, current Element) error {
 func validateReflectType(v reflect.Value, def schema.Element, position int64) error {
 	switch def.Type {
 	default:
-		return &DecodeTypeError{EBMLType: def.Type, Type: v.Type(), Offset: position}
+		return newDecodeTypeError(v, def, position)
 
 	case TypeMaster:
 		switch v.Kind() {
 		default:
-			return &DecodeTypeError{EBMLType: def.Type, Type: v.Type(), Offset: position}
+			return newDecodeTypeError(v, def, position)
 		case reflect.Struct:
 			// valid type
 		}
 
 	case TypeBinary:
+		if _, ok := binaryUnmarshaler(v); ok {
+			return nil
+		}
 		switch v.Kind() {
 		default:
 			switch v.Type() {
 			default:
-				return &DecodeTypeError{EBMLType: def.Type, Type: v.Type(), Offset: position}
-			case typeElementID:
+				return newDecodeTypeError(v, def, position)
+			case typeElementID, typeBigInt:
 				// valid type
 			}
-		case reflect.Slice:
+		case reflect.String:
+			// valid type
+		case reflect.Slice, reflect.Array:
 			e := v.Type().Elem()
 			if e.Kind() != reflect.Uint8 {
-				return &DecodeTypeError{EBMLType: def.Type, Type: v.Type(), Offset: position}
+				return newDecodeTypeError(v, def, position)
 			}
 		}
 
 	case TypeDate:
 		switch v.Type() {
 		default:
-			return &DecodeTypeError{EBMLType: def.Type, Type: v.Type(), Offset: position}
+			return newDecodeTypeError(v, def, position)
 		case typeTime:
 			// valid type
 		}
@@ -344,7 +532,7 @@ func validateReflectType(v reflect.Value, def schema.Element, position int64) er
 	case TypeFloat:
 		switch v.Kind() {
 		default:
-			return &DecodeTypeError{EBMLType: def.Type, Type: v.Type(), Offset: position}
+			return newDecodeTypeError(v, def, position)
 		case reflect.Float32, reflect.Float64:
 			// valid type
 		}
@@ -352,8 +540,8 @@ func validateReflectType(v reflect.Value, def schema.Element, position int64) er
 	case TypeInteger:
 		switch v.Kind() {
 		default:
-			return &DecodeTypeError{EBMLType: def.Type, Type: v.Type(), Offset: position}
-		case reflect.Int, reflect.Int64, reflect.Int32:
+			return newDecodeTypeError(v, def, position)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 			// valid type
 		}
 
@@ -362,22 +550,106 @@ func validateReflectType(v reflect.Value, def schema.Element, position int64) er
 		default:
 			switch v.Type() {
 			default:
-				return &DecodeTypeError{EBMLType: def.Type, Type: v.Type(), Offset: position}
+				return newDecodeTypeError(v, def, position)
 			case typeDuration:
 				// valid type
 			}
-		case reflect.Uint, reflect.Uint64, reflect.Uint32:
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Bool:
 			// valid type
 		}
 
 	case TypeString, TypeUTF8:
+		if _, ok := textUnmarshaler(v); ok {
+			return nil
+		}
 		if v.Kind() != reflect.String {
-			return &DecodeTypeError{EBMLType: def.Type, Type: v.Type(), Offset: position}
+			return newDecodeTypeError(v, def, position)
 		}
 	}
 	return nil
 }
 
+func newDecodeTypeError(v reflect.Value, def schema.Element, position int64) *DecodeTypeError {
+	return &DecodeTypeError{EBMLType: def.Type, Type: v.Type(), Offset: position, ID: def.ID, SchemaPath: def.Path}
+}
+
+// binaryUnmarshaler returns the encoding.BinaryUnmarshaler implemented
+// by the address of v.
+func binaryUnmarshaler(v reflect.Value) (encoding.BinaryUnmarshaler, bool) {
+	if !v.CanAddr() {
+		return nil, false
+	}
+	u, ok := v.Addr().Interface().(encoding.BinaryUnmarshaler)
+	return u, ok
+}
+
+// textUnmarshaler returns the encoding.TextUnmarshaler implemented
+// by the address of v.
+func textUnmarshaler(v reflect.Value) (encoding.TextUnmarshaler, bool) {
+	if !v.CanAddr() {
+		return nil, false
+	}
+	u, ok := v.Addr().Interface().(encoding.TextUnmarshaler)
+	return u, ok
+}
+
+func setInteger(v reflect.Value, i int64, def schema.Element, position int64) error {
+	if v.OverflowInt(i) {
+		e := newDecodeTypeError(v, def, position)
+		e.Value = strconv.FormatInt(i, 10)
+		return e
+	}
+	v.SetInt(i)
+	return nil
+}
+
+func setUinteger(v reflect.Value, u uint64, def schema.Element, position int64) error {
+	switch {
+	case v.Type() == typeDuration:
+		if u > math.MaxInt64 {
+			e := newDecodeTypeError(v, def, position)
+			e.Value = strconv.FormatUint(u, 10)
+			return e
+		}
+		v.SetInt(int64(u))
+	case v.Kind() == reflect.Bool:
+		if u > 1 {
+			e := newDecodeTypeError(v, def, position)
+			e.Value = strconv.FormatUint(u, 10)
+			return e
+		}
+		v.SetBool(u == 1)
+	default:
+		if v.OverflowUint(u) {
+			e := newDecodeTypeError(v, def, position)
+			e.Value = strconv.FormatUint(u, 10)
+			return e
+		}
+		v.SetUint(u)
+	}
+	return nil
+}
+
+func setFloat(v reflect.Value, f float64, def schema.Element, position int64) error {
+	if v.OverflowFloat(f) {
+		e := newDecodeTypeError(v, def, position)
+		e.Value = strconv.FormatFloat(f, 'g', -1, 64)
+		return e
+	}
+	v.SetFloat(f)
+	return nil
+}
+
+func setString(v reflect.Value, str string) error {
+	if u, ok := textUnmarshaler(v); ok {
+		return u.UnmarshalText([]byte(str))
+	}
+	v.SetString(str)
+	return nil
+}
+
+// DefaultAllocationWindow is the size of the internal buffers allocated
+// for binary values decoded with BinaryAlias.
 var DefaultAllocationWindow = int64(1<<24) - 1
 
 func (d *Decoder) decodeSingle(el Element, val reflect.Value) error {
@@ -396,96 +668,234 @@ func (d *Decoder) decodeSingle(el Element, val reflect.Value) error {
 			val = v.Index(n)
 		}
 	}
-	if err := validateReflectType(val, sch, 0); err != nil {
-		if e, ok := err.(*DecodeTypeError); ok {
-			e.extendError(sch.Name)
-		}
+	if err := validateReflectType(val, sch, el.Offset); err != nil {
 		return err
 	}
 
-	pos := d.r.InputOffset()
-
 	if sch.Type == TypeMaster {
-		err := d.decodeMaster(val, el)
-		if d.callback != nil {
-			d.callback = d.callback.Decoded(el, pos-int64(d.n), d.n, val.Interface())
+		if err := d.decodeMaster(val, el); err != nil {
+			return err
 		}
-		return err
+		return d.decoded(el, val.Interface())
+	}
+	if el.DataSize == -1 {
+		return newElementError(el, ErrUnknownSizeNotAllowed)
 	}
 
-	if int64(cap(d.window)) < el.DataSize {
-		n := DefaultAllocationWindow
-		for n < el.DataSize {
-			n = (n << 1) + 1
-		}
-		d.window = make([]byte, n)
+	alloc := d.scratch
+	if _, ok := binaryUnmarshaler(val); !ok && sch.Type == TypeBinary && val.Kind() == reflect.Slice {
+		// Read directly into the value to avoid a copy.
+		alloc = d.allocBinary
 	}
-	b := d.window[:el.DataSize]
-	if _, err := io.ReadFull(d.r, b); err != nil {
+	b, err := d.readAlloc(el, alloc)
+	if err == nil {
+		err = setData(val, el, orDefault(sch, b))
+	}
+	if err != nil {
+		if sch.Default != nil {
+			// The value of an invalid element is its default value.
+			if b, derr := defaultData(sch); derr == nil {
+				setData(val, el, b)
+			}
+		}
 		return err
 	}
+	return d.decoded(el, val.Interface())
+}
 
+// setData sets val to the value of el with the data b. The type of val
+// must be valid for el, see validateReflectType.
+func setData(val reflect.Value, el Element, b []byte) error {
+	sch := el.Schema
 	switch sch.Type {
 	case TypeBinary:
-		switch val.Type() {
+		if u, ok := binaryUnmarshaler(val); ok {
+			if err := u.UnmarshalBinary(b); err != nil {
+				return newElementError(el, err)
+			}
+			break
+		}
+		switch val.Kind() {
 		default:
-			d.window = d.window[el.DataSize:]
-			val.SetBytes(b)
-		case typeElementID:
-			i, err := ebmltext.Uint(b)
-			if err != nil {
-				return err
+			switch val.Type() {
+			case typeElementID:
+				i, err := ebmltext.Uint(b)
+				if err != nil {
+					return newElementError(el, err)
+				}
+				val.SetUint(i)
+			case typeBigInt:
+				val.Addr().Interface().(*big.Int).SetBytes(b)
 			}
-			val.SetUint(i)
+		case reflect.String:
+			val.SetString(string(b))
+		case reflect.Array:
+			if len(b) != 0 && len(b) != val.Len() {
+				return newElementError(el, fmt.Errorf("ebml: binary of %d octets does not fit into %s", len(b), val.Type()))
+			}
+			val.SetZero()
+			reflect.Copy(val, reflect.ValueOf(b))
+		case reflect.Slice:
+			val.SetBytes(b)
 		}
 
 	case TypeDate:
 		t, err := ebmltext.Date(b)
 		if err != nil {
-			return err
+			return newElementError(el, err)
 		}
 		val.Set(reflect.ValueOf(t))
 
 	case TypeFloat:
 		f, err := ebmltext.Float(b)
 		if err != nil {
+			return newElementError(el, err)
+		}
+		if err := setFloat(val, f, sch, el.Offset); err != nil {
 			return err
 		}
-		val.SetFloat(f)
 
 	case TypeInteger:
 		i, err := ebmltext.Int(b)
 		if err != nil {
+			return newElementError(el, err)
+		}
+		if err := setInteger(val, i, sch, el.Offset); err != nil {
 			return err
 		}
-		val.SetInt(i)
 
 	case TypeUinteger:
-		switch val.Type() {
-		default:
-			i, err := ebmltext.Uint(b)
-			if err != nil {
-				return err
-			}
-			val.SetUint(i)
-		case typeDuration:
-			i, err := ebmltext.Int(b)
-			if err != nil {
-				return err
-			}
-			val.SetInt(i)
+		i, err := ebmltext.Uint(b)
+		if err != nil {
+			return newElementError(el, err)
+		}
+		if err := setUinteger(val, i, sch, el.Offset); err != nil {
+			return err
 		}
 
 	case TypeString, TypeUTF8:
 		str, err := ebmltext.String(b)
 		if err != nil {
-			return err
+			return newElementError(el, err)
+		}
+		if err := setString(val, str); err != nil {
+			return newElementError(el, err)
 		}
-		val.SetString(str)
 	}
+	return nil
+}
 
-	if d.callback != nil {
-		d.callback = d.callback.Decoded(el, pos-int64(d.n), d.n, val.Interface())
+// readData reads the data of el into b.
+func (d *Decoder) readData(el Element, b []byte) error {
+	if el.DataSize == -1 {
+		return newElementError(el, ErrUnknownSizeNotAllowed)
+	}
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return newElementError(el, err)
 	}
 	return nil
 }
+
+// Unmarshaler is the interface implemented by types that can decode the
+// children of a master element themselves, without reflection.
+//
+// DecodeEBML is called after the header of el was read. It is expected to
+// consume the data of el, typically with DecodeChildren and the Read
+// methods of d, and to apply the default values of the children.
+type Unmarshaler interface {
+	DecodeEBML(d *Decoder, el Element) error
+}
+
+// ReadInteger reads the data of el as a signed integer.
+func (d *Decoder) ReadInteger(el Element) (int64, error) {
+	b, err := d.readAlloc(el, d.scratch)
+	if err != nil {
+		return 0, err
+	}
+	b = orDefault(el.Schema, b)
+	i, err := ebmltext.Int(b)
+	if err != nil {
+		return 0, newElementError(el, err)
+	}
+	return i, d.decoded(el, i)
+}
+
+// ReadUinteger reads the data of el as an unsigned integer.
+func (d *Decoder) ReadUinteger(el Element) (uint64, error) {
+	b, err := d.readAlloc(el, d.scratch)
+	if err != nil {
+		return 0, err
+	}
+	b = orDefault(el.Schema, b)
+	i, err := ebmltext.Uint(b)
+	if err != nil {
+		return 0, newElementError(el, err)
+	}
+	return i, d.decoded(el, i)
+}
+
+// ReadFloat reads the data of el as a float.
+func (d *Decoder) ReadFloat(el Element) (float64, error) {
+	b, err := d.readAlloc(el, d.scratch)
+	if err != nil {
+		return 0, err
+	}
+	b = orDefault(el.Schema, b)
+	f, err := ebmltext.Float(b)
+	if err != nil {
+		return 0, newElementError(el, err)
+	}
+	return f, d.decoded(el, f)
+}
+
+// ReadString reads the data of el as a string or an utf-8 string.
+func (d *Decoder) ReadString(el Element) (string, error) {
+	b, err := d.readAlloc(el, d.scratch)
+	if err != nil {
+		return "", err
+	}
+	b = orDefault(el.Schema, b)
+	str, err := ebmltext.String(b)
+	if err != nil {
+		return "", newElementError(el, err)
+	}
+	return str, d.decoded(el, str)
+}
+
+// ReadDate reads the data of el as a date.
+func (d *Decoder) ReadDate(el Element) (time.Time, error) {
+	b, err := d.readAlloc(el, d.scratch)
+	if err != nil {
+		return time.Time{}, err
+	}
+	b = orDefault(el.Schema, b)
+	t, err := ebmltext.Date(b)
+	if err != nil {
+		return time.Time{}, newElementError(el, err)
+	}
+	return t, d.decoded(el, t)
+}
+
+// ReadBinary reads the data of el as binary. The storage of the
+// returned slice follows the BinaryMode of d.
+func (d *Decoder) ReadBinary(el Element) ([]byte, error) {
+	b, err := d.readAlloc(el, d.allocBinary)
+	if err != nil {
+		return nil, err
+	}
+	b = orDefault(el.Schema, b)
+	return b, d.decoded(el, b)
+}
+
+// DecodeMaster decodes the master element el into u and triggers the
+// Visitor of d like Decode does. It is meant to be used by
+// implementations of Unmarshaler for their master children.
+func (d *Decoder) DecodeMaster(el Element, u Unmarshaler) error {
+	if err := u.DecodeEBML(d, el); err != nil {
+		return err
+	}
+	return d.decoded(el, u)
+}
@@ -0,0 +1,669 @@
+package ebml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/ebml/ebmltext"
+	"github.com/coding-socks/ebml/schema"
+)
+
+var (
+	testIDTest           schema.ElementID = 0x18538067
+	testIDInfo           schema.ElementID = 0x1549a966
+	testIDTitle          schema.ElementID = 0x7ba9
+	testIDTimestampScale schema.ElementID = 0x2ad7b1
+	testIDDuration       schema.ElementID = 0x4489
+	testIDDateUTC        schema.ElementID = 0x4461
+	testIDOffset         schema.ElementID = 0x4462
+	testIDCluster        schema.ElementID = 0x1f43b675
+	testIDTimestamp      schema.ElementID = 0xe7
+	testIDPayload        schema.ElementID = 0xa3
+)
+
+type testDocument struct {
+	Info    testInfo
+	Cluster []testCluster
+}
+
+type testInfo struct {
+	Title          string
+	TimestampScale uint
+	Duration       float64
+	DateUTC        time.Time
+	Offset         int
+}
+
+type testCluster struct {
+	Timestamp uint
+	Payload   [][]byte
+}
+
+func init() {
+	b, err := os.ReadFile("testdata/test.xml")
+	if err != nil {
+		panic(err)
+	}
+	var s schema.Schema
+	if err := xml.Unmarshal(b, &s); err != nil {
+		panic(err)
+	}
+	Register("test", s)
+}
+
+// testElement returns the encoded form of an element with the given
+// children or data.
+func testElement(id schema.ElementID, data ...[]byte) []byte {
+	body := bytes.Join(data, nil)
+	var buf bytes.Buffer
+	enc := ebmltext.NewEncoder(&buf)
+	if _, err := enc.WriteElementID(id); err != nil {
+		panic(err)
+	}
+	if _, err := enc.WriteElementDataSize(int64(len(body)), 0); err != nil {
+		panic(err)
+	}
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// testUnknownSizeElement returns the header of an element with unknown
+// data size followed by the given children.
+func testUnknownSizeElement(id schema.ElementID, data ...[]byte) []byte {
+	var buf bytes.Buffer
+	enc := ebmltext.NewEncoder(&buf)
+	if _, err := enc.WriteElementID(id); err != nil {
+		panic(err)
+	}
+	if _, err := enc.WriteElementDataSize(-1, 1); err != nil {
+		panic(err)
+	}
+	buf.Write(bytes.Join(data, nil))
+	return buf.Bytes()
+}
+
+func testHeader(docType string) []byte {
+	return testElement(IDEBML,
+		testElement(IDEBMLMaxIDLength, []byte{4}),
+		testElement(IDEBMLMaxSizeLength, []byte{8}),
+		testElement(IDDocType, []byte(docType)),
+	)
+}
+
+func TestDecoder_DecodeHeader(t *testing.T) {
+	d := NewDecoder(bytes.NewReader(testHeader("test")))
+	h, err := d.DecodeHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &EBML{
+		EBMLVersion:        1,
+		EBMLReadVersion:    1,
+		EBMLMaxIDLength:    4,
+		EBMLMaxSizeLength:  8,
+		DocType:            "test",
+		DocTypeVersion:     1,
+		DocTypeReadVersion: 1,
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("DecodeHeader() = %+v, want %+v", h, want)
+	}
+}
+
+func TestDecoder_DecodeHeader_unknownDocType(t *testing.T) {
+	b := append(testHeader("unknown"), testElement(testIDTest, testElement(testIDInfo))...)
+	d := NewDecoder(bytes.NewReader(b))
+	h, err := d.DecodeHeader()
+	var unknown UnknownDocTypeError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("DecodeHeader() error = %v, want UnknownDocTypeError", err)
+	}
+	if h == nil || h.DocType != "unknown" {
+		t.Errorf("DecodeHeader() = %+v", h)
+	}
+	el, _, err := d.NextOf(RootEl, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if el.ID != testIDTest || el.Schema.Name != UnknownSchema.Name {
+		t.Errorf("NextOf() = %v %q, want %v with UnknownSchema", el.ID, el.Schema.Name, testIDTest)
+	}
+}
+
+func TestDecoder_Resync(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		want        []uint
+		wantDamaged []DamagedRange
+	}{
+		{
+			name: "garbage between top-level elements",
+			body: testUnknownSizeElement(testIDTest,
+				testElement(testIDInfo, testElement(testIDTitle, []byte("a"))),
+				[]byte{0x00, 0x00, 0x00},
+				testElement(testIDCluster, testElement(testIDTimestamp, []byte{1})),
+				testElement(testIDCluster, testElement(testIDTimestamp, []byte{2})),
+			),
+			want:        []uint{1, 2},
+			wantDamaged: []DamagedRange{{Start: 14, End: 17}},
+		},
+		{
+			name: "garbage larger than the resync window",
+			body: testUnknownSizeElement(testIDTest,
+				testElement(testIDInfo, testElement(testIDTitle, []byte("a"))),
+				make([]byte, 2*resyncWindowSize+5),
+				testElement(testIDCluster, testElement(testIDTimestamp, []byte{1})),
+			),
+			want:        []uint{1},
+			wantDamaged: []DamagedRange{{Start: 14, End: 14 + 2*resyncWindowSize + 5}},
+		},
+		{
+			name: "overwritten data in known size master",
+			body: testUnknownSizeElement(testIDTest,
+				testElement(testIDInfo, testElement(testIDTitle, []byte("a"))),
+				testElement(testIDCluster, testElement(testIDTimestamp, []byte{1}), []byte{0xff, 0xff, 0xff, 0xff}),
+				testElement(testIDCluster, testElement(testIDTimestamp, []byte{2})),
+			),
+			want:        []uint{1, 2},
+			wantDamaged: []DamagedRange{{Start: 22, End: 26}},
+		},
+		{
+			name: "unknown element overflowing its parent",
+			body: testUnknownSizeElement(testIDTest,
+				testElement(testIDInfo, testElement(testIDTitle, []byte("a")), []byte{0x81, 0x88}),
+				testElement(testIDCluster, testElement(testIDTimestamp, []byte{2})),
+			),
+			want:        []uint{2},
+			wantDamaged: []DamagedRange{{Start: 14, End: 16}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := testHeader("test")
+			d := NewDecoder(bytes.NewReader(append(header, tt.body...)))
+			if _, err := d.DecodeHeader(); err != nil {
+				t.Fatal(err)
+			}
+			var doc testDocument
+			err := d.DecodeBody(&doc)
+			var derr *DamagedDataError
+			if !errors.As(err, &derr) {
+				t.Errorf("DecodeBody() error = %v, want DamagedDataError", err)
+			}
+			if doc.Info.Title != "a" {
+				t.Errorf("Title = %q, want %q", doc.Info.Title, "a")
+			}
+			var got []uint
+			for _, c := range doc.Cluster {
+				got = append(got, c.Timestamp)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Cluster timestamps = %v, want %v", got, tt.want)
+			}
+			var wantDamaged []DamagedRange
+			for _, r := range tt.wantDamaged {
+				r.Start += int64(len(header))
+				r.End += int64(len(header))
+				wantDamaged = append(wantDamaged, r)
+			}
+			if got := d.Damaged(); !reflect.DeepEqual(got, wantDamaged) {
+				t.Errorf("Damaged() = %v, want %v", got, wantDamaged)
+			}
+		})
+	}
+}
+
+func TestDecoder_DecodeBody_errors(t *testing.T) {
+	type badInfo struct {
+		Title int
+	}
+	type badDocument struct {
+		Info badInfo
+	}
+	header := testHeader("test")
+	hl := int64(len(header))
+	t.Run("type error", func(t *testing.T) {
+		body := testElement(testIDTest, testElement(testIDInfo, testElement(testIDTitle, []byte("a"))))
+		d := NewDecoder(bytes.NewReader(append(header, body...)))
+		if _, err := d.DecodeHeader(); err != nil {
+			t.Fatal(err)
+		}
+		var doc badDocument
+		err := d.DecodeBody(&doc)
+		var e *DecodeTypeError
+		if !errors.As(err, &e) {
+			t.Fatalf("DecodeBody() error = %v, want DecodeTypeError", err)
+		}
+		if want := hl + 10; e.Offset != want {
+			t.Errorf("Offset = %d, want %d", e.Offset, want)
+		}
+		if want := "badDocument.Info.Title"; e.Path != want {
+			t.Errorf("Path = %q, want %q", e.Path, want)
+		}
+		if want := `\Test\Info\Title`; e.SchemaPath != want {
+			t.Errorf("SchemaPath = %q, want %q", e.SchemaPath, want)
+		}
+		if e.ID != testIDTitle {
+			t.Errorf("ID = %v, want %v", e.ID, testIDTitle)
+		}
+	})
+	t.Run("truncated element", func(t *testing.T) {
+		body := testElement(testIDTest, testElement(testIDInfo, testElement(testIDTitle, []byte("abc"))))
+		d := NewDecoder(bytes.NewReader(append(header, body[:len(body)-1]...)))
+		if _, err := d.DecodeHeader(); err != nil {
+			t.Fatal(err)
+		}
+		var doc testDocument
+		err := d.DecodeBody(&doc)
+		var e *ElementError
+		if !errors.As(err, &e) {
+			t.Fatalf("DecodeBody() error = %v, want ElementError", err)
+		}
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("DecodeBody() error = %v, want %v", err, io.ErrUnexpectedEOF)
+		}
+		if want := hl + 10; e.Offset != want {
+			t.Errorf("Offset = %d, want %d", e.Offset, want)
+		}
+		if want := "testDocument.Info.Title"; e.Path != want {
+			t.Errorf("Path = %q, want %q", e.Path, want)
+		}
+	})
+	t.Run("syntax error", func(t *testing.T) {
+		d := NewDecoder(bytes.NewReader(append(header, 0x00, 0x00, 0x00, 0x00)))
+		if _, err := d.DecodeHeader(); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err := d.NextOf(RootEl, 0)
+		var e *SyntaxError
+		if !errors.As(err, &e) {
+			t.Fatalf("NextOf() error = %v, want SyntaxError", err)
+		}
+		if e.Offset != hl {
+			t.Errorf("Offset = %d, want %d", e.Offset, hl)
+		}
+		if !errors.Is(err, ErrInvalidVINTLength) {
+			t.Errorf("NextOf() error = %v, want %v", err, ErrInvalidVINTLength)
+		}
+	})
+	t.Run("header overflow", func(t *testing.T) {
+		// The header of Title ends after the end of Info.
+		body := testElement(testIDTest, testElement(testIDInfo, []byte{0x7b}), []byte{0xa9, 0x85})
+		d := NewDecoder(bytes.NewReader(append(header, body...)))
+		if _, err := d.DecodeHeader(); err != nil {
+			t.Fatal(err)
+		}
+		var doc testDocument
+		if err := d.DecodeBody(&doc); !errors.Is(err, ErrElementOverflow) {
+			t.Errorf("DecodeBody() error = %v, want %v", err, ErrElementOverflow)
+		}
+	})
+	t.Run("huge data size", func(t *testing.T) {
+		payload := []byte{0xa3, 0x01, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe, 1, 2, 3}
+		body := testUnknownSizeElement(testIDTest, testUnknownSizeElement(testIDCluster, payload))
+		d := NewDecoder(bytes.NewReader(append(header, body...)))
+		if _, err := d.DecodeHeader(); err != nil {
+			t.Fatal(err)
+		}
+		var doc testDocument
+		if err := d.DecodeBody(&doc); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("DecodeBody() error = %v, want %v", err, io.ErrUnexpectedEOF)
+		}
+	})
+}
+
+type testText string
+
+func (t *testText) UnmarshalText(b []byte) error {
+	*t = testText(strings.ToUpper(string(b)))
+	return nil
+}
+
+type testBinary []byte
+
+func (t *testBinary) UnmarshalBinary(b []byte) error {
+	*t = append((*t)[:0], b...)
+	slices.Reverse(*t)
+	return nil
+}
+
+func TestDecoder_Decode_types(t *testing.T) {
+	decode := func(t *testing.T, el []byte, v any) error {
+		t.Helper()
+		d := NewDecoder(bytes.NewReader(append(testHeader("test"), el...)))
+		if _, err := d.DecodeHeader(); err != nil {
+			t.Fatal(err)
+		}
+		return d.DecodeBody(v)
+	}
+	cluster := func(timestamp []byte, payload ...[]byte) []byte {
+		children := [][]byte{testElement(testIDTimestamp, timestamp)}
+		for _, p := range payload {
+			children = append(children, testElement(testIDPayload, p))
+		}
+		return testElement(testIDTest, testElement(testIDCluster, children...))
+	}
+	t.Run("narrow integers", func(t *testing.T) {
+		var doc struct {
+			Cluster []struct {
+				Timestamp uint8
+			}
+		}
+		if err := decode(t, cluster([]byte{0xff}), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if got := doc.Cluster[0].Timestamp; got != 0xff {
+			t.Errorf("Timestamp = %d, want %d", got, 0xff)
+		}
+	})
+	t.Run("overflow", func(t *testing.T) {
+		var doc struct {
+			Cluster []struct {
+				Timestamp uint8
+			}
+		}
+		err := decode(t, cluster([]byte{0x01, 0x00}), &doc)
+		var e *DecodeTypeError
+		if !errors.As(err, &e) {
+			t.Fatalf("DecodeBody() error = %v, want DecodeTypeError", err)
+		}
+		if e.Value != "256" {
+			t.Errorf("Value = %q, want %q", e.Value, "256")
+		}
+	})
+	t.Run("bool", func(t *testing.T) {
+		var doc struct {
+			Cluster []struct {
+				Timestamp bool
+			}
+		}
+		if err := decode(t, cluster([]byte{0x01}), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if !doc.Cluster[0].Timestamp {
+			t.Errorf("Timestamp = false, want true")
+		}
+		err := decode(t, cluster([]byte{0x02}), &doc)
+		if e := (*DecodeTypeError)(nil); !errors.As(err, &e) {
+			t.Fatalf("DecodeBody() error = %v, want DecodeTypeError", err)
+		}
+	})
+	t.Run("binary", func(t *testing.T) {
+		type payloads struct {
+			Array   [3]byte
+			String  string
+			BigInt  *big.Int
+			Binary  testBinary
+			Strings []string
+		}
+		var got payloads
+		for i, ptr := range []any{&got.Array, &got.String, &got.BigInt, &got.Binary, &got.Strings} {
+			typ := reflect.StructOf([]reflect.StructField{
+				{Name: "Timestamp", Type: reflect.TypeOf(uint(0))},
+				{Name: "Payload", Type: reflect.TypeOf(ptr).Elem()},
+			})
+			v := reflect.New(reflect.StructOf([]reflect.StructField{
+				{Name: "Cluster", Type: typ},
+			}))
+			if err := decode(t, cluster([]byte{0x01}, []byte{1, 2, 3}), v.Interface()); err != nil {
+				t.Fatalf("%d: %v", i, err)
+			}
+			reflect.ValueOf(ptr).Elem().Set(v.Elem().Field(0).Field(1))
+		}
+		want := payloads{
+			Array:   [3]byte{1, 2, 3},
+			String:  "\x01\x02\x03",
+			BigInt:  big.NewInt(0x010203),
+			Binary:  testBinary{3, 2, 1},
+			Strings: []string{"\x01\x02\x03"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Payload = %+v, want %+v", got, want)
+		}
+	})
+	t.Run("text unmarshaler", func(t *testing.T) {
+		var doc struct {
+			Info struct {
+				Title testText
+			}
+		}
+		body := testElement(testIDTest, testElement(testIDInfo, testElement(testIDTitle, []byte("abc"))))
+		if err := decode(t, body, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if got := doc.Info.Title; got != "ABC" {
+			t.Errorf("Title = %q, want %q", got, "ABC")
+		}
+	})
+}
+
+const testDefaultsSchema = `<EBMLSchema docType="defaults" version="1">
+  <element name="Defaults" path="\Defaults" id="0x19000001" type="master"/>
+  <element name="Info" path="\Defaults\Info" id="0x4101" type="master" minOccurs="1" maxOccurs="1"/>
+  <element name="Date" path="\Defaults\Info\Date" id="0x81" type="date" default="86400000000000" maxOccurs="1"/>
+  <element name="Key" path="\Defaults\Info\Key" id="0x82" type="binary" default="0x0102" maxOccurs="1"/>
+  <element name="Name" path="\Defaults\Info\Name" id="0x83" type="utf-8" default="none" maxOccurs="1"/>
+  <element name="Track" path="\Defaults\Info\Track" id="0x4102" type="master" minOccurs="1" maxOccurs="1"/>
+  <element name="Number" path="\Defaults\Info\Track\Number" id="0x84" type="uinteger" default="1" maxOccurs="1"/>
+  <element name="Tag" path="\Defaults\Tag" id="0x4103" type="master"/>
+  <element name="Value" path="\Defaults\Tag\Value" id="0x85" type="integer" default="-2" maxOccurs="1"/>
+  <element name="Level" path="\Defaults\Level" id="0x86" type="uinteger" default="3"/>
+</EBMLSchema>`
+
+type testDefaults struct {
+	Presence
+	Info struct {
+		Presence
+		Date  time.Time
+		Key   []byte
+		Name  string
+		Track *struct {
+			Number uint
+		}
+	}
+	Tag *struct {
+		Value int
+	}
+	Level []uint
+}
+
+func TestDecoder_Decode_defaults(t *testing.T) {
+	var s schema.Schema
+	if err := xml.Unmarshal([]byte(testDefaultsSchema), &s); err != nil {
+		t.Fatal(err)
+	}
+	def, err := NewDef(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decode := func(t *testing.T, children ...[]byte) testDefaults {
+		t.Helper()
+		d := NewDecoder(bytes.NewReader(testElement(def.Root.ID, children...)))
+		d.def = def
+		el, _, err := d.NextOf(RootEl, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v testDefaults
+		if err := d.Decode(el, &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	day := time.Date(2001, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	t.Run("absent", func(t *testing.T) {
+		v := decode(t)
+		if !v.Info.Date.Equal(day) {
+			t.Errorf("Date = %v, want %v", v.Info.Date, day)
+		}
+		if want := []byte{1, 2}; !bytes.Equal(v.Info.Key, want) {
+			t.Errorf("Key = %x, want %x", v.Info.Key, want)
+		}
+		if v.Info.Name != "none" {
+			t.Errorf("Name = %q, want %q", v.Info.Name, "none")
+		}
+		if v.Info.Track == nil || v.Info.Track.Number != 1 {
+			t.Errorf("Track = %+v, want a Track with Number 1", v.Info.Track)
+		}
+		if v.Tag != nil {
+			t.Errorf("Tag = %+v, want nil for an optional master", v.Tag)
+		}
+		if want := []uint{3}; !slices.Equal(v.Level, want) {
+			t.Errorf("Level = %v, want %v", v.Level, want)
+		}
+		for field, want := range map[string]Source{"Info": SourceDefault, "Tag": SourceAbsent, "Level": SourceDefault} {
+			if got := v.Source(field); got != want {
+				t.Errorf("Source(%q) = %v, want %v", field, got, want)
+			}
+		}
+		if got := v.Info.Source("Track"); got != SourceDefault {
+			t.Errorf("Info.Source(%q) = %v, want %v", "Track", got, SourceDefault)
+		}
+	})
+	t.Run("present", func(t *testing.T) {
+		v := decode(t,
+			testElement(0x4101,
+				testElement(0x81),
+				testElement(0x82),
+				testElement(0x83, []byte("x")),
+			),
+			testElement(0x4103),
+			testElement(0x86, []byte{5}),
+			testElement(0x86, []byte{6}),
+		)
+		if !v.Info.Date.Equal(day) {
+			t.Errorf("empty Date = %v, want %v", v.Info.Date, day)
+		}
+		if want := []byte{1, 2}; !bytes.Equal(v.Info.Key, want) {
+			t.Errorf("empty Key = %x, want %x", v.Info.Key, want)
+		}
+		if v.Info.Name != "x" {
+			t.Errorf("Name = %q, want %q", v.Info.Name, "x")
+		}
+		if v.Tag == nil || v.Tag.Value != -2 {
+			t.Errorf("Tag = %+v, want a Tag with Value -2", v.Tag)
+		}
+		if want := []uint{5, 6}; !slices.Equal(v.Level, want) {
+			t.Errorf("Level = %v, want %v", v.Level, want)
+		}
+		if !v.Present("Info") || !v.Info.Present("Date") || !v.Info.Present("Name") {
+			t.Errorf("Info, Date and Name are not present")
+		}
+		if got := v.Info.Source("Track"); got != SourceDefault {
+			t.Errorf("Info.Source(%q) = %v, want %v", "Track", got, SourceDefault)
+		}
+	})
+}
+
+// testDefaultsTrack is a Track which decodes itself.
+type testDefaultsTrack struct {
+	Number uint
+}
+
+func (tr *testDefaultsTrack) DecodeEBML(d *Decoder, el Element) error {
+	tr.Number = 7
+	return d.Skip(el)
+}
+
+func TestDecoder_Decode_defaultsUnmarshaler(t *testing.T) {
+	var s schema.Schema
+	if err := xml.Unmarshal([]byte(testDefaultsSchema), &s); err != nil {
+		t.Fatal(err)
+	}
+	def, err := NewDef(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDecoder(bytes.NewReader(testElement(def.Root.ID, testElement(0x4101))))
+	d.def = def
+	el, _, err := d.NextOf(RootEl, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Info struct {
+			Track []testDefaultsTrack
+		}
+	}
+	if err := d.Decode(el, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Info.Track != nil {
+		t.Errorf("Track = %+v, want nil for an absent Unmarshaler", v.Info.Track)
+	}
+}
+
+func TestNewDef_invalidDefault(t *testing.T) {
+	for _, typ := range []string{TypeInteger, TypeUinteger, TypeFloat, TypeDate, TypeBinary} {
+		bad := "bad"
+		s := schema.Schema{Elements: []schema.Element{
+			{Name: "Root", Path: `\Root`, ID: 0x19000001, Type: TypeMaster},
+			{Name: "Value", Path: `\Root\Value`, ID: 0x81, Type: typ, Default: &bad},
+		}}
+		if _, err := NewDef(s); err == nil {
+			t.Errorf("NewDef() with %s default %q succeeded, want error", typ, bad)
+		}
+	}
+}
+
+func TestDecoder_Decode_meta(t *testing.T) {
+	header := testHeader("test")
+	body := testElement(testIDTest,
+		testElement(testIDInfo, testElement(testIDTitle, []byte("a"))),
+		testElement(testIDCluster, testElement(testIDTimestamp, []byte{1})),
+		testElement(testIDCluster, testElement(testIDTimestamp, []byte{2})),
+	)
+	d := NewDecoder(bytes.NewReader(append(header, body...)))
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Meta
+		Info struct {
+			Meta
+			Title          string
+			TimestampScale uint
+			Offset         int
+			Duration       float64
+		}
+		Cluster []testCluster
+	}
+	if err := d.DecodeBody(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	h := int64(len(header))
+	info, ok := doc.Meta.Info("Info")
+	if want := (ElementInfo{Source: SourceDocument, Offset: h + 5, HeaderSize: 5, DataSize: 4}); !ok || info != want {
+		t.Errorf("Info(%q) = %+v, %v, want %+v", "Info", info, ok, want)
+	}
+	clusters := doc.All("Cluster")
+	if len(clusters) != 2 || clusters[0].Offset != h+14 || clusters[1].Offset != h+14+8 {
+		t.Errorf("All(%q) = %+v, want Clusters at offsets %d and %d", "Cluster", clusters, h+14, h+14+8)
+	}
+	title, _ := doc.Info.Info("Title")
+	if want := (ElementInfo{Source: SourceDocument, Offset: h + 10, HeaderSize: 3, DataSize: 1}); title != want {
+		t.Errorf("Info.Info(%q) = %+v, want %+v", "Title", title, want)
+	}
+	scale, _ := doc.Info.Info("TimestampScale")
+	if want := (ElementInfo{Source: SourceDefault, Offset: h + 5}); scale != want {
+		t.Errorf("Info.Info(%q) = %+v, want %+v", "TimestampScale", scale, want)
+	}
+	if _, ok := doc.Info.Info("Duration"); ok {
+		t.Errorf("Info.Info(%q) reports an absent element without default", "Duration")
+	}
+	if got := doc.Info.Source("Offset"); got != SourceDefault {
+		t.Errorf("Info.Source(%q) = %v, want %v", "Offset", got, SourceDefault)
+	}
+}
@@ -0,0 +1,159 @@
+package ebml
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/coding-socks/ebml/ebmltext"
+	"github.com/coding-socks/ebml/schema"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultData returns the canonical form of the default value of sch.
+//
+// Following RFC 8794, integers and unsigned integers are written in
+// decimal, floats in decimal or hexadecimal notation, and binaries in
+// hexadecimal with an optional 0x prefix. Dates are written as the
+// nanoseconds since 2001-01-01T00:00:00 UTC, or in RFC 3339 format.
+func defaultData(sch schema.Element) ([]byte, error) {
+	s := *sch.Default
+	switch sch.Type {
+	case TypeInteger:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return appendCanonical(nil, sch.Type, ebmltext.AppendInt(nil, i))
+	case TypeUinteger:
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return appendCanonical(nil, sch.Type, ebmltext.AppendUint(nil, u))
+	case TypeFloat:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return appendCanonicalFloat(nil, f), nil
+	case TypeDate:
+		var b []byte
+		if ns, err := strconv.ParseInt(s, 10, 64); err == nil {
+			b = binary.BigEndian.AppendUint64(nil, uint64(ns))
+		} else if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			b = ebmltext.AppendDate(nil, t)
+		} else {
+			return nil, fmt.Errorf("ebml: invalid date %q", s)
+		}
+		return appendCanonical(nil, sch.Type, b)
+	case TypeString, TypeUTF8:
+		return appendCanonical(nil, sch.Type, []byte(s))
+	case TypeBinary:
+		return hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	}
+	return nil, fmt.Errorf("ebml: default not supported for %s", sch.Type)
+}
+
+// orDefault returns the data of an element of sch read as b. The value
+// of an empty element is the default value of its schema, if it has one.
+func orDefault(sch schema.Element, b []byte) []byte {
+	if len(b) != 0 || sch.Default == nil {
+		return b
+	}
+	if def, err := defaultData(sch); err == nil {
+		return def
+	}
+	return b
+}
+
+// applyDefaults sets the fields of the struct val which were not decoded
+// from the children of the master element with the given path. Fields of
+// elements with a default value get that value, and fields of mandatory
+// master elements get a value which holds the defaults of its children.
+//
+// seen tells which fields of tinfo were decoded, it is nil when none were.
+func (d *Decoder) applyDefaults(val reflect.Value, tinfo *typeInfo, path string, offset int64, seen []bool) error {
+	fields := tinfo.fieldsByID(d.def)
+	r := tinfo.recorder(val)
+	for sel := range d.def.Children(path) {
+		if sel.Default == nil && (sel.Type != TypeMaster || sel.MinOccurs < 1 || sel.Recursive) {
+			continue
+		}
+		finfo, found := fields[sel.ID]
+		if !found || seen != nil && seen[finfo.pos] {
+			continue
+		}
+		fieldv, err := fieldByIndex(val, finfo.idx)
+		if err != nil {
+			return newElementError(Element{Offset: offset, ID: sel.ID, Schema: sel}, err)
+		}
+		if err := d.setDefault(fieldv, sel, offset); err != nil {
+			extendFieldPath(err, finfo.goName)
+			return err
+		}
+		if r != nil {
+			r.record(finfo.goName, ElementInfo{Source: SourceDefault, Offset: offset})
+		}
+	}
+	return nil
+}
+
+// setDefault sets val to the default value of sch, or to a value which
+// holds the defaults of the children of sch for a master element.
+func (d *Decoder) setDefault(val reflect.Value, sch schema.Element, offset int64) error {
+	if sch.Type == TypeMaster && !holdsDefaults(val.Type()) {
+		// Only the type itself knows how to apply the defaults.
+		return nil
+	}
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
+	if v := val; v.Kind() == reflect.Slice {
+		e := v.Type().Elem()
+		if !(sch.Type == TypeBinary && e.Kind() == reflect.Uint8) {
+			n := v.Len()
+			v.Set(reflect.Append(v, reflect.Zero(e)))
+			val = v.Index(n)
+		}
+	}
+	if sch.Type != TypeMaster {
+		if err := validateReflectType(val, sch, offset); err != nil {
+			return err
+		}
+		el := Element{Offset: offset, ID: sch.ID, Schema: sch}
+		b, err := defaultData(sch)
+		if err != nil {
+			return newElementError(el, err)
+		}
+		return setData(val, el, b)
+	}
+	tinfo, err := getTypeInfo(val.Type())
+	if err != nil {
+		return err
+	}
+	if r := tinfo.recorder(val); r != nil {
+		r.reset()
+	}
+	return d.applyDefaults(val, tinfo, sch.Path, offset, nil)
+}
+
+var typeUnmarshaler = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+// holdsDefaults reports whether a field of type typ is able to hold the
+// defaults of the children of a master element. It must be a struct, or a
+// pointer to or a slice of a struct, which is not an Unmarshaler.
+func holdsDefaults(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct && !reflect.PointerTo(typ).Implements(typeUnmarshaler)
+}
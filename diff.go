@@ -0,0 +1,369 @@
+package ebml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/coding-socks/ebml/schema"
+	"io"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ChangeKind tells how an element differs between two documents.
+type ChangeKind int
+
+const (
+	// ChangeAdded marks an element which is only in the second document.
+	ChangeAdded ChangeKind = iota
+	// ChangeRemoved marks an element which is only in the first document.
+	ChangeRemoved
+	// ChangeModified marks an element which is in both documents with a
+	// different value or layout.
+	ChangeModified
+)
+
+var changeKindNames = []string{"added", "removed", "modified"}
+
+func (k ChangeKind) String() string {
+	if int(k) < len(changeKindNames) {
+		return changeKindNames[k]
+	}
+	return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *ChangeKind) UnmarshalText(b []byte) error {
+	i := slices.Index(changeKindNames, string(b))
+	if i < 0 {
+		return fmt.Errorf("ebml: unknown change kind %q", b)
+	}
+	*k = ChangeKind(i)
+	return nil
+}
+
+// A Change is a difference found by Diff.
+//
+// Path locates the element by the names of its ancestors. An element
+// which occurs more than once in its parent is followed by its zero based
+// index among the elements with the same ID, like \Segment\Cluster[3].
+type Change struct {
+	Kind ChangeKind       `json:"kind"`
+	Path string           `json:"path"`
+	ID   schema.ElementID `json:"id"`
+	// OffsetA and OffsetB are the offsets of the element in the first
+	// and the second document, or -1 when it is missing from one.
+	OffsetA int64 `json:"offsetA"`
+	OffsetB int64 `json:"offsetB"`
+	// What tells what changed for ChangeModified: "value", "size" when
+	// only one of the elements has an unknown data size, or "sizewidth"
+	// when the Element Data Sizes have different widths.
+	What string `json:"what,omitempty"`
+	// A and B describe what changed in the first and the second document.
+	A string `json:"a,omitempty"`
+	B string `json:"b,omitempty"`
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s (b: %d)", c.Path, c.OffsetB)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s (a: %d)", c.Path, c.OffsetA)
+	}
+	return fmt.Sprintf("~ %s (a: %d, b: %d) %s: %s -> %s", c.Path, c.OffsetA, c.OffsetB, c.What, c.A, c.B)
+}
+
+// DiffOptions configures Diff.
+type DiffOptions struct {
+	// IgnoreVoid ignores Void elements.
+	IgnoreVoid bool
+	// IgnoreCRC ignores CRC-32 elements.
+	IgnoreCRC bool
+	// IgnoreDataSize ignores how the Element Data Sizes are written:
+	// their width, and whether master elements have an unknown data size.
+	IgnoreDataSize bool
+}
+
+// Diff reads the EBML Documents a and b and returns the elements which
+// were added, removed or changed from a to b.
+//
+// Elements are aligned by their path and by their index among the
+// children of their parent with the same ID, so a change of the data size
+// of an element does not affect the comparison of the elements after it.
+// Values are compared after decoding: an integer written with a leading
+// zero octet is the same as the one without it, and a string is the same
+// as the string padded with null octets.
+func Diff(a, b io.Reader, opts DiffOptions) ([]Change, error) {
+	docA, err := readDiffDocument(a, opts)
+	if err != nil {
+		return nil, fmt.Errorf("ebml: first document: %w", err)
+	}
+	docB, err := readDiffDocument(b, opts)
+	if err != nil {
+		return nil, fmt.Errorf("ebml: second document: %w", err)
+	}
+	var changes []Change
+	diffChildren(&changes, "", docA, docB, opts)
+	return changes, nil
+}
+
+// A diffNode is an element read by Diff.
+type diffNode struct {
+	el       Element
+	value    any // as Node.Value, but binary data is a binaryDigest
+	children []*diffNode
+}
+
+// A binaryDigest stands for binary data which is compared without being
+// kept in memory.
+type binaryDigest struct {
+	size int64
+	head []byte
+	sum  [sha256.Size]byte
+}
+
+// binaryHeadSize is the number of octets kept to describe binary data.
+const binaryHeadSize = 16
+
+func (b binaryDigest) String() string {
+	if len(b.head) == 0 {
+		return fmt.Sprintf("%d octets", b.size)
+	}
+	s := hex.EncodeToString(b.head)
+	if int64(len(b.head)) < b.size {
+		s += fmt.Sprintf("... (%d octets)", b.size)
+	}
+	return s
+}
+
+func readDiffDocument(r io.Reader, opts DiffOptions) ([]*diffNode, error) {
+	d := NewDecoder(r)
+	var nodes []*diffNode
+	for {
+		el, _, err := d.NextOf(RootEl, 0)
+		if isDamage(err) {
+			if err := d.recover(d.r.InputOffset(), err); err != nil {
+				return nil, errors.Join(err, d.skippedErrs)
+			}
+			continue
+		}
+		if err == io.EOF {
+			return nodes, d.skippedErrs
+		}
+		if err != nil {
+			return nil, err
+		}
+		if el.ID == IDEBML {
+			d.def = HeaderDef
+			d.r.MaxIDLength = DefaultMaxIDLength
+			d.r.MaxSizeLength = DefaultMaxSizeLength
+		}
+		n, err := d.readDiffNode(el, opts)
+		if err != nil {
+			return nil, err
+		}
+		if n == nil {
+			continue
+		}
+		nodes = append(nodes, n)
+		if el.ID == IDEBML {
+			h := &Node{}
+			for _, c := range n.children {
+				h.Children = append(h.Children, &Node{ID: c.el.ID, Value: c.value})
+			}
+			docType, idLen, sizeLen := headerParams(h)
+			if d.def, err = Definition(docType); err != nil {
+				return nil, err
+			}
+			d.r.MaxIDLength, d.r.MaxSizeLength = idLen, sizeLen
+		}
+	}
+}
+
+// readDiffNode reads el and its descendants. It returns nil for the elements
+// ignored by opts.
+func (d *Decoder) readDiffNode(el Element, opts DiffOptions) (*diffNode, error) {
+	if el.ID == IDVoid && opts.IgnoreVoid || el.ID == IDCRC32 && opts.IgnoreCRC {
+		return nil, d.Skip(el)
+	}
+	n := &diffNode{el: el}
+	var err error
+	switch el.Schema.Type {
+	case TypeMaster:
+		err = d.DecodeChildren(el, func(el Element) error {
+			child, err := d.readDiffNode(el, opts)
+			if child != nil {
+				n.children = append(n.children, child)
+			}
+			return err
+		})
+	case TypeInteger:
+		n.value, err = d.ReadInteger(el)
+	case TypeUinteger:
+		n.value, err = d.ReadUinteger(el)
+	case TypeFloat:
+		n.value, err = d.ReadFloat(el)
+	case TypeString, TypeUTF8:
+		var s string
+		s, err = d.ReadString(el)
+		n.value = strings.TrimRight(s, "\x00")
+	case TypeDate:
+		n.value, err = d.ReadDate(el)
+	default:
+		n.value, err = d.readDigest(el)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// readDigest reads the data of el into a binaryDigest. The data of Void
+// elements is not read, only their size is compared.
+func (d *Decoder) readDigest(el Element) (binaryDigest, error) {
+	v := binaryDigest{size: el.DataSize}
+	if el.ID == IDVoid {
+		return v, d.Skip(el)
+	}
+	if el.DataSize == -1 {
+		return v, newElementError(el, ErrUnknownSizeNotAllowed)
+	}
+	v.head = make([]byte, min(el.DataSize, binaryHeadSize))
+	if err := d.readData(el, v.head); err != nil {
+		return v, err
+	}
+	h := sha256.New()
+	h.Write(v.head)
+	if _, err := io.CopyN(h, d.r, el.DataSize-int64(len(v.head))); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return v, newElementError(el, err)
+	}
+	h.Sum(v.sum[:0])
+	return v, nil
+}
+
+// diffChildren aligns the children of two elements with the path parent.
+func diffChildren(changes *[]Change, parent string, a, b []*diffNode, opts DiffOptions) {
+	var ids []schema.ElementID
+	byID := make(map[schema.ElementID][2][]*diffNode)
+	for i, nodes := range [][]*diffNode{a, b} {
+		for _, n := range nodes {
+			group, ok := byID[n.el.ID]
+			if !ok {
+				ids = append(ids, n.el.ID)
+			}
+			group[i] = append(group[i], n)
+			byID[n.el.ID] = group
+		}
+	}
+	for _, id := range ids {
+		group := byID[id]
+		na, nb := len(group[0]), len(group[1])
+		for i := range max(na, nb) {
+			var x, y *diffNode
+			if i < na {
+				x = group[0][i]
+			}
+			if i < nb {
+				y = group[1][i]
+			}
+			n := x
+			if n == nil {
+				n = y
+			}
+			name := n.el.Schema.Name
+			if n.el.Schema.Name == UnknownSchema.Name {
+				name = id.String()
+			}
+			path := parent + "\\" + name
+			if max(na, nb) > 1 {
+				path += "[" + strconv.Itoa(i) + "]"
+			}
+			c := Change{Path: path, ID: id, OffsetA: -1, OffsetB: -1}
+			switch {
+			case y == nil:
+				c.Kind, c.OffsetA = ChangeRemoved, x.el.Offset
+				*changes = append(*changes, c)
+			case x == nil:
+				c.Kind, c.OffsetB = ChangeAdded, y.el.Offset
+				*changes = append(*changes, c)
+			default:
+				c.Kind, c.OffsetA, c.OffsetB = ChangeModified, x.el.Offset, y.el.Offset
+				diffNodes(changes, c, x, y, opts)
+			}
+		}
+	}
+}
+
+// diffNodes compares two elements aligned by diffChildren, and records
+// their differences based on c.
+func diffNodes(changes *[]Change, c Change, x, y *diffNode, opts DiffOptions) {
+	add := func(what, a, b string) {
+		c.What, c.A, c.B = what, a, b
+		*changes = append(*changes, c)
+	}
+	if !opts.IgnoreDataSize {
+		switch ux, uy := x.el.DataSize == -1, y.el.DataSize == -1; {
+		case ux != uy:
+			add("size", formatDataSize(x.el.DataSize), formatDataSize(y.el.DataSize))
+		case sizeWidth(x.el) != sizeWidth(y.el):
+			add("sizewidth", strconv.Itoa(sizeWidth(x.el)), strconv.Itoa(sizeWidth(y.el)))
+		}
+	}
+	if x.el.Schema.Type == TypeMaster {
+		diffChildren(changes, c.Path, x.children, y.children, opts)
+		return
+	}
+	if !diffValueEqual(x.value, y.value) {
+		add("value", formatDiffValue(x.value), formatDiffValue(y.value))
+	}
+}
+
+// sizeWidth returns the width of the Element Data Size of el.
+func sizeWidth(el Element) int {
+	return el.HeaderSize - (bits.Len64(uint64(el.ID))+7)/8
+}
+
+func formatDataSize(ds int64) string {
+	if ds == -1 {
+		return "unknown"
+	}
+	return strconv.FormatInt(ds, 10)
+}
+
+func diffValueEqual(a, b any) bool {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		return ok && (a == b || a != a && b != b)
+	case time.Time:
+		b, ok := b.(time.Time)
+		return ok && a.Equal(b)
+	case binaryDigest:
+		b, ok := b.(binaryDigest)
+		return ok && a.size == b.size && a.sum == b.sum
+	}
+	return a == b
+}
+
+func formatDiffValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,98 @@
+package ebml
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/coding-socks/ebml/ebmltext"
+)
+
+func TestDiff(t *testing.T) {
+	payload := bytes.Repeat([]byte{1}, 100)
+	changed := bytes.Repeat([]byte{1}, 100)
+	changed[50] = 2
+	a := append(testHeader("test"), testUnknownSizeElement(testIDTest,
+		testElement(testIDInfo,
+			testElement(testIDTitle, []byte("title")),
+			testElement(testIDTimestampScale, []byte{0x03, 0xe8}),
+		),
+		testElement(testIDCluster, testElement(testIDTimestamp, []byte{1}), testElement(testIDPayload, payload)),
+	)...)
+
+	var title bytes.Buffer
+	enc := ebmltext.NewEncoder(&title)
+	if _, err := enc.WriteElementID(testIDTitle); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.WriteElementDataSize(7, 8); err != nil {
+		t.Fatal(err)
+	}
+	title.WriteString("title\x00\x00")
+	b := append(testHeader("test"), testElement(testIDTest,
+		testElement(testIDInfo,
+			title.Bytes(),
+			testElement(testIDTimestampScale, []byte{0, 0, 0x03, 0xe8}),
+			testElement(IDVoid, []byte{0, 0}),
+		),
+		testElement(testIDCluster, testElement(testIDTimestamp, []byte{1}), testElement(testIDPayload, changed)),
+		testElement(testIDCluster, testElement(testIDTimestamp, []byte{2})),
+	)...)
+
+	changes, err := Diff(bytes.NewReader(a), bytes.NewReader(b), DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Kind.String()+" "+c.Path+" "+c.What)
+	}
+	want := []string{
+		`modified \Test size`,
+		`modified \Test\Info\Title sizewidth`,
+		`added \Test\Info\Void `,
+		`modified \Test\Cluster[0]\Payload value`,
+		`added \Test\Cluster[1] `,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() =\n%q\nwant\n%q", got, want)
+	}
+	if c := changes[3]; c.A != "01010101010101010101010101010101... (100 octets)" || c.OffsetA < 0 || c.OffsetB < 0 {
+		t.Errorf("Payload change = %+v", c)
+	}
+	if c := changes[4]; c.OffsetA != -1 || c.OffsetB != int64(bytes.LastIndex(b, []byte{0x1f, 0x43, 0xb6, 0x75})) {
+		t.Errorf("Cluster change = %+v", c)
+	}
+
+	changes, err = Diff(bytes.NewReader(a), bytes.NewReader(b), DiffOptions{IgnoreVoid: true, IgnoreCRC: true, IgnoreDataSize: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Path != `\Test\Cluster[0]\Payload` || changes[1].Path != `\Test\Cluster[1]` {
+		t.Errorf("Diff() = %+v, want the Payload and Cluster changes", changes)
+	}
+
+	changes, err = Diff(bytes.NewReader(a), bytes.NewReader(a), DiffOptions{})
+	if err != nil || len(changes) != 0 {
+		t.Errorf("Diff(a, a) = %+v, %v, want no changes", changes, err)
+	}
+}
+
+func TestChange_json(t *testing.T) {
+	c := Change{Kind: ChangeModified, Path: `\Test\Info\Title`, ID: testIDTitle, OffsetA: 1, OffsetB: 2, What: "value", A: `"a"`, B: `"b"`}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Change
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != c {
+		t.Errorf("Unmarshal(%s) = %+v, want %+v", b, got, c)
+	}
+	if s, want := c.String(), `~ \Test\Info\Title (a: 1, b: 2) value: "a" -> "b"`; s != want {
+		t.Errorf("String() = %s, want %s", s, want)
+	}
+}
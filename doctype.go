@@ -42,3 +42,133 @@ type DocTypeExtension struct {
 	DocTypeExtensionName    string
 	DocTypeExtensionVersion uint
 }
+
+// DecodeEBML implements Unmarshaler.
+func (v *EBML) DecodeEBML(d *Decoder, el Element) error {
+	v.EBMLVersion = 1
+	v.EBMLReadVersion = 1
+	v.EBMLMaxIDLength = 4
+	v.EBMLMaxSizeLength = 8
+	v.DocTypeVersion = 1
+	v.DocTypeReadVersion = 1
+	return d.DecodeChildren(el, func(el Element) error {
+		switch el.ID {
+		case IDEBMLVersion:
+			x, err := d.ReadUinteger(el)
+			if err != nil {
+				return err
+			}
+			v.EBMLVersion = uint(x)
+		case IDEBMLReadVersion:
+			x, err := d.ReadUinteger(el)
+			if err != nil {
+				return err
+			}
+			v.EBMLReadVersion = uint(x)
+		case IDEBMLMaxIDLength:
+			x, err := d.ReadUinteger(el)
+			if err != nil {
+				return err
+			}
+			v.EBMLMaxIDLength = uint(x)
+		case IDEBMLMaxSizeLength:
+			x, err := d.ReadUinteger(el)
+			if err != nil {
+				return err
+			}
+			v.EBMLMaxSizeLength = uint(x)
+		case IDDocType:
+			x, err := d.ReadString(el)
+			if err != nil {
+				return err
+			}
+			v.DocType = x
+		case IDDocTypeVersion:
+			x, err := d.ReadUinteger(el)
+			if err != nil {
+				return err
+			}
+			v.DocTypeVersion = uint(x)
+		case IDDocTypeReadVersion:
+			x, err := d.ReadUinteger(el)
+			if err != nil {
+				return err
+			}
+			v.DocTypeReadVersion = uint(x)
+		case IDDocTypeExtension:
+			var x DocTypeExtension
+			if err := d.DecodeMaster(el, &x); err != nil {
+				return err
+			}
+			v.DocTypeExtension = append(v.DocTypeExtension, x)
+		default:
+			return d.Skip(el)
+		}
+		return nil
+	})
+}
+
+// EncodeEBML implements Marshaler.
+func (v *EBML) EncodeEBML(e *Encoder) error {
+	if err := e.WriteUinteger(IDEBMLVersion, uint64(v.EBMLVersion)); err != nil {
+		return err
+	}
+	if err := e.WriteUinteger(IDEBMLReadVersion, uint64(v.EBMLReadVersion)); err != nil {
+		return err
+	}
+	if err := e.WriteUinteger(IDEBMLMaxIDLength, uint64(v.EBMLMaxIDLength)); err != nil {
+		return err
+	}
+	if err := e.WriteUinteger(IDEBMLMaxSizeLength, uint64(v.EBMLMaxSizeLength)); err != nil {
+		return err
+	}
+	if err := e.WriteString(IDDocType, v.DocType); err != nil {
+		return err
+	}
+	if err := e.WriteUinteger(IDDocTypeVersion, uint64(v.DocTypeVersion)); err != nil {
+		return err
+	}
+	if err := e.WriteUinteger(IDDocTypeReadVersion, uint64(v.DocTypeReadVersion)); err != nil {
+		return err
+	}
+	for i := range v.DocTypeExtension {
+		if err := e.WriteMaster(IDDocTypeExtension, &v.DocTypeExtension[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeEBML implements Unmarshaler.
+func (v *DocTypeExtension) DecodeEBML(d *Decoder, el Element) error {
+	return d.DecodeChildren(el, func(el Element) error {
+		switch el.ID {
+		case IDDocTypeExtensionName:
+			x, err := d.ReadString(el)
+			if err != nil {
+				return err
+			}
+			v.DocTypeExtensionName = x
+		case IDDocTypeExtensionVersion:
+			x, err := d.ReadUinteger(el)
+			if err != nil {
+				return err
+			}
+			v.DocTypeExtensionVersion = uint(x)
+		default:
+			return d.Skip(el)
+		}
+		return nil
+	})
+}
+
+// EncodeEBML implements Marshaler.
+func (v *DocTypeExtension) EncodeEBML(e *Encoder) error {
+	if err := e.WriteString(IDDocTypeExtensionName, v.DocTypeExtensionName); err != nil {
+		return err
+	}
+	if err := e.WriteUinteger(IDDocTypeExtensionVersion, uint64(v.DocTypeExtensionVersion)); err != nil {
+		return err
+	}
+	return nil
+}
@@ -14,7 +14,6 @@ import (
 	"io"
 	"iter"
 	"maps"
-	"reflect"
 	"slices"
 	"sort"
 	"strings"
@@ -43,15 +42,22 @@ func init() {
 }
 
 type Def struct {
-	m      map[schema.ElementID]schema.Element
-	mfield map[string][]schema.Element
-	Root   schema.Element
+	m        map[schema.ElementID]schema.Element
+	mfield   map[string][]schema.Element
+	children map[string][]schema.Element
+	names    map[string][]schema.ElementID
+	Root     schema.Element
+
+	// fields caches the fields of each type by Element ID.
+	fields sync.Map // map[*typeInfo]map[schema.ElementID]*fieldInfo
 }
 
 func NewDef(s schema.Schema) (*Def, error) {
 	def := Def{
-		m:      make(map[schema.ElementID]schema.Element, len(s.Elements)),
-		mfield: make(map[string][]schema.Element, len(s.Elements)),
+		m:        make(map[schema.ElementID]schema.Element, len(s.Elements)),
+		mfield:   make(map[string][]schema.Element, len(s.Elements)),
+		children: make(map[string][]schema.Element, len(s.Elements)),
+		names:    make(map[string][]schema.ElementID, len(s.Elements)),
 	}
 	set := make(map[schema.ElementID]bool, len(s.Elements))
 	var bodyRoots []schema.Element
@@ -59,12 +65,19 @@ func NewDef(s schema.Schema) (*Def, error) {
 		if el.Type == TypeMaster && el.Default != nil {
 			return nil, fmt.Errorf("ebml: master Element %v MUST NOT declare a default value.", el.ID)
 		}
+		if el.Default != nil {
+			if _, err := defaultData(el); err != nil {
+				return nil, fmt.Errorf("ebml: invalid default value of Element %v: %w", el.ID, err)
+			}
+		}
 		set[el.ID] = true
 		def.m[el.ID] = el
+		def.names[el.Name] = append(def.names[el.Name], el.ID)
 
+		i := strings.LastIndex(el.Path, "\\")
+		parent := el.Path[:i]
+		def.children[parent] = append(def.children[parent], el)
 		if el.Type != TypeMaster {
-			i := strings.LastIndex(el.Path, "\\")
-			parent := el.Path[:i]
 			def.mfield[parent] = append(def.mfield[parent], el)
 		}
 
@@ -81,6 +94,10 @@ func NewDef(s schema.Schema) (*Def, error) {
 			continue
 		}
 		def.m[el.ID] = el
+		def.names[el.Name] = append(def.names[el.Name], el.ID)
+		i := strings.LastIndex(el.Path, "\\")
+		parent := el.Path[:i]
+		def.children[parent] = append(def.children[parent], el)
 	}
 	return &def, nil
 }
@@ -97,6 +114,27 @@ func (d *Def) Fields(path string) iter.Seq[schema.Element] {
 	return slices.Values(d.mfield[path])
 }
 
+// Children returns the elements defined directly inside the element
+// with the given path, including master elements.
+func (d *Def) Children(path string) iter.Seq[schema.Element] {
+	return slices.Values(d.children[path])
+}
+
+// childByName returns the element named name inside the element with
+// the given path.
+func (d *Def) childByName(path, name string) (schema.Element, bool) {
+	for _, sch := range d.children[path] {
+		if sch.Name == name {
+			return sch, true
+		}
+	}
+	// Global and recursive elements have a path which differs from their parent.
+	if ids := d.names[name]; len(ids) == 1 {
+		return d.m[ids[0]], true
+	}
+	return schema.Element{}, false
+}
+
 func (d *Def) All() iter.Seq[schema.Element] {
 	return maps.Values(d.m)
 }
@@ -162,68 +200,100 @@ type Element struct {
 	// With 8 octets it can have 2^56-2 possible values. That fits into int64.
 	DataSize int64
 
+	// Offset is the input offset of the first octet of the Element ID.
+	Offset int64
+	// HeaderSize is the number of octets used by the Element ID and
+	// the Element Data Size.
+	HeaderSize int
+
 	Schema schema.Element
 }
 
+// A RawElement is an element kept in its encoded form. A struct field of
+// type []RawElement with the ",unknown" option collects the children
+// which do not match any other field, and the Encoder writes them back.
+type RawElement struct {
+	ID schema.ElementID
+	// Data is the Element Data. For a master element it holds
+	// the encoded children.
+	Data []byte
+}
+
 // A Decoder represents an EBML parser reading a particular input stream.
 type Decoder struct {
 	r   *ebmltext.Decoder
 	def *Def
 
 	el *Element
-	n  int
 	// skippedErrs signals to return errors at the end of Decode.
 	skippedErrs error
 
-	window    []byte
-	typeInfos map[reflect.Type]*typeInfo
-
-	callback Callbacker
+	window     []byte
+	arena      []byte
+	binaryMode BinaryMode
+	alloc      Allocator
+
+	resyncIDs []schema.ElementID
+	damaged   []DamagedRange
+
+	visitor Visitor
+	filter  Filter
+	// ancestors holds the elements whose children are being read.
+	ancestors []Element
+	// elVisited tells that the pushed back el was visited.
+	elVisited bool
+	// quiet suppresses the visits while it is positive.
+	quiet int
+	// onSkip is called for the elements skipped by the Visitor, so that
+	// the struct being decoded records them as present.
+	onSkip func(parent, el Element)
 }
 
 // NewDecoder reads and parses an EBML Document from r.
 func NewDecoder(r io.Reader) *Decoder {
+	tr := ebmltext.NewDecoder(r)
+	tr.MaxIDLength = DefaultMaxIDLength
+	tr.MaxSizeLength = DefaultMaxSizeLength
 	return &Decoder{
-		r:   ebmltext.NewDecoder(r),
+		r:   tr,
 		def: HeaderDef,
 
-		typeInfos: make(map[reflect.Type]*typeInfo),
+		alloc: makeAllocator{},
 	}
 }
 
-// SetCallback adds a Callbacker which is triggered when NextOf reads element id
-// and data size, and when a value is successfully decoded.
-func (d *Decoder) SetCallback(c Callbacker) {
-	d.callback = c
-}
-
 // next reads the following element id and data size.
 //
 // When next encounters an ErrInvalidVINTLength or the element has UnknownSchema,
 // it could be caused by damaged data or garbage in the stream. It is up
-// to the caller to decide if they want to skip to the next element or
-// move the reader forward by seeking one byte using io.SeekCurrent whence.
+// to the caller to decide if they want to skip to the next element,
+// move the reader forward by seeking one byte using io.SeekCurrent whence,
+// or call Resync.
 func (d *Decoder) next() (el Element, n int, err error) {
+	el.Offset = d.r.InputOffset()
 	el.ID, err = d.r.ReadElementID()
-	if err != nil {
+	if err == io.EOF {
 		return Element{}, n, err
 	}
+	if err != nil {
+		return Element{}, n, &SyntaxError{Offset: el.Offset, Err: err}
+	}
 	n += d.r.Release()
 	el.DataSize, err = d.r.ReadElementDataSize()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
 	if err != nil {
-		return Element{}, n, err
+		return Element{}, n, &SyntaxError{Offset: el.Offset + int64(n), Err: err}
 	}
 	n += d.r.Release()
-	d.n = n
+	el.HeaderSize = n
 	sch, ok := d.def.Get(el.ID)
 	if !ok {
 		el.Schema = UnknownSchema
 	} else {
 		el.Schema = sch
 	}
-	if d.callback != nil {
-		d.callback = d.callback.Found(el, d.r.InputOffset()-int64(n), n)
-	}
 	return el, n, err
 }
 
@@ -238,29 +308,54 @@ var RootEl = Element{DataSize: -1, Schema: schema.Element{Name: "root", Type: Ty
 // When NextOf encounters ErrElementOverflow fo known data size,
 // you can skip the parent object, or you can read until the parent ends.
 //
+// NextOf calls the Visitor of d for the element, and it skips the
+// elements which the Visitor asks to skip.
+//
 // See Next about ErrInvalidVINTLength.
 func (d *Decoder) NextOf(parent Element, offset int64) (el Element, n int, err error) {
+	for {
+		var visited bool
+		el, n, visited, err = d.nextOf(parent, offset)
+		if visited || err != nil && !errors.Is(err, ErrElementOverflow) {
+			return el, n, err
+		}
+		start := d.r.InputOffset()
+		skipped, verr := d.visit(parent, offset, el, n, err)
+		if verr != nil {
+			return Element{}, n, verr
+		}
+		if !skipped {
+			return el, n, err
+		}
+		offset += int64(n) + d.r.InputOffset() - start
+	}
+}
+
+// nextOf is NextOf without the Visitor. It reports whether the returned
+// element was already visited, because it was pushed back.
+func (d *Decoder) nextOf(parent Element, offset int64) (el Element, n int, visited bool, err error) {
 	if end := d.EndOfKnownDataSize(parent, offset); end {
-		return Element{}, 0, io.EOF
+		return Element{}, 0, false, io.EOF
 	}
 	if d.el != nil {
-		el = *d.el
+		el, visited = *d.el, d.elVisited
 		d.el = nil
 	} else {
 		el, n, err = d.next()
 		if err != nil {
-			return Element{}, n, err
+			return Element{}, n, false, err
 		}
 	}
-	if parent.DataSize != -1 && offset+el.DataSize > parent.DataSize {
-		err = ErrElementOverflow
+	// A pushed back element has its header already counted in offset.
+	if parent.DataSize != -1 && offset+int64(n)+el.DataSize > parent.DataSize {
+		err = newElementError(el, ErrElementOverflow)
 	}
 	if end := d.EndOfUnknownDataSize(parent, el); end {
 		tmp := el // This is unexpected. I cannot use the pointer to the return parameter variable.
-		d.el = &tmp
-		return Element{}, 0, io.EOF
+		d.el, d.elVisited = &tmp, visited
+		return Element{}, 0, false, io.EOF
 	}
-	return el, n, err
+	return el, n, visited, err
 }
 
 func (d *Decoder) AsSeeker() (io.Seeker, bool) {
@@ -301,17 +396,18 @@ func (d *Decoder) EndOfUnknownDataSize(parent Element, el Element) bool {
 	if parent.DataSize != -1 {
 		return false
 	}
+	return !isChild(parent, el)
+}
+
+// isChild reports whether the schema path of el places it inside parent.
+func isChild(parent Element, el Element) bool {
 	if el.ID == IDCRC32 || el.ID == IDVoid { // global elements are child of anything
-		return false
+		return true
+	}
+	if parent.Schema.Path == RootEl.Schema.Path { // including elements without schema
+		return true
 	}
 	parentSch := parent.Schema
 	elSch := el.Schema
-	return !strings.HasPrefix(elSch.Path, parentSch.Path) || len(elSch.Path) == len(parentSch.Path)
-}
-
-type Callbacker interface {
-	// Found is called whenever a new element is found in the target io.Reader.
-	Found(el Element, offset int64, headerSize int) Callbacker
-	// Decoded is called whenever an element is decoded from the target io.Reader.
-	Decoded(el Element, offset int64, headerSize int, val any) Callbacker
+	return strings.HasPrefix(elSch.Path, parentSch.Path) && len(elSch.Path) != len(parentSch.Path)
 }
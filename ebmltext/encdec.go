@@ -1,12 +1,14 @@
 package ebmltext
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/coding-socks/ebml/schema"
 	"io"
 	"math"
+	"math/bits"
 	"time"
 )
 
@@ -59,15 +61,12 @@ func (d *Decoder) ReadElementID() (id schema.ElementID, err error) {
 	if len(window) == 0 {
 		return 0, io.EOF
 	}
-	vint, w, err := ReadVint(window[:d.MaxIDLength])
+	id, w, err := ParseElementID(window, d.MaxIDLength)
 	if err != nil {
-		return 0, ErrInvalidVINTWidth
-	}
-	if vintAllOne(vint) {
-		return 0, ErrAllOneVINT
+		return 0, err
 	}
 	d.releasable = w
-	return schema.ElementID(vint), nil
+	return id, nil
 }
 
 // ReadElementDataSize reads an Element Data Size based on
@@ -80,18 +79,52 @@ func (d *Decoder) ReadElementDataSize() (ds int64, err error) {
 	if len(window) == 0 {
 		return 0, io.EOF
 	}
-	vintD, w, err := ReadVintData(window[:d.MaxSizeLength])
+	ds, w, err := ParseElementDataSize(window, d.MaxSizeLength)
 	if err != nil {
 		return 0, err
 	}
-	if w > int(d.MaxSizeLength) {
-		return 0, ErrInvalidVINTWidth
-	}
 	d.releasable = w
+	return ds, nil
+}
+
+// ParseElementID parses an Element ID from the beginning of b without
+// consuming any input. It returns the ID and the number of octets it
+// occupies.
+func ParseElementID(b []byte, maxIDLength uint) (id schema.ElementID, w int, err error) {
+	vint, w, err := ReadVint(b[:min(uint(len(b)), maxIDLength)])
+	if err != nil {
+		return 0, 0, ErrInvalidVINTWidth
+	}
+	if vintAllOne(vint) {
+		return 0, 0, ErrAllOneVINT
+	}
+	return schema.ElementID(vint), w, nil
+}
+
+// ParseElementDataSize parses an Element Data Size from the beginning
+// of b without consuming any input. It returns the data size, or -1
+// for unknown data size, and the number of octets it occupies.
+func ParseElementDataSize(b []byte, maxSizeLength uint) (ds int64, w int, err error) {
+	vintD, w, err := ReadVintData(b[:min(uint(len(b)), maxSizeLength)])
+	if err != nil {
+		return 0, 0, ErrInvalidVINTWidth
+	}
+	if w > int(maxSizeLength) {
+		return 0, 0, ErrInvalidVINTWidth
+	}
 	if vintDataAllOne(vintD, w) {
-		return -1, nil
+		return -1, w, nil
 	}
-	return int64(vintD), nil
+	return int64(vintD), w, nil
+}
+
+// Peek returns the next n bytes without advancing the reader.
+// Fewer than n bytes are returned only when the underlying reader
+// cannot provide more. The returned slice is invalidated by the next
+// read operation.
+func (d *Decoder) Peek(n int) []byte {
+	b := d.r.peek(n)
+	return b[:min(len(b), n)]
 }
 
 // Release releases n bytes from the internal buffer after reading
@@ -136,9 +169,12 @@ func (s DecodeSeeker) Seek(offset int64, whence int) (int64, error) {
 // Int reads an int64 based on https://www.rfc-editor.org/rfc/rfc8794.html#section-7.1
 func Int(b []byte) (int64, error) {
 	if len(b) > 8 {
-		return 0, errors.New("ebml: max length for an unsigned integer is eight octets")
+		return 0, errors.New("ebml: max length for a signed integer is eight octets")
 	}
 	i := int64(0)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		i = -1 // sign extension
+	}
 	for _, bb := range b {
 		i = (i << 8) | int64(bb)
 	}
@@ -175,12 +211,23 @@ func Float(b []byte) (float64, error) {
 }
 
 // String reads a string based on https://www.rfc-editor.org/rfc/rfc8794.html#section-7.5
+//
+// The value ends at the first null octet, see
+// https://www.rfc-editor.org/rfc/rfc8794.html#section-13
 func String(b []byte) (string, error) {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
 	return string(b), nil
 }
 
 // Date reads a time,Time based on https://www.rfc-editor.org/rfc/rfc8794.html#section-7.6
 func Date(b []byte) (time.Time, error) {
+	// A Date Element MUST declare a length of either
+	// zero octets or eight octets.
+	if len(b) != 0 && len(b) != 8 {
+		return time.Time{}, errors.New("ebml: data length must be 0 bit or 64 bit for a date")
+	}
 	i, err := Int(b)
 	if err != nil {
 		return time.Time{}, err
@@ -188,6 +235,51 @@ func Date(b []byte) (time.Time, error) {
 	return thirdMillennium.Add(time.Nanosecond * time.Duration(i)), nil
 }
 
+// AppendInt appends the shortest big-endian two's complement representation
+// of i to b based on https://www.rfc-editor.org/rfc/rfc8794.html#section-7.1
+func AppendInt(b []byte, i int64) []byte {
+	n := 1
+	for ; n < 8; n++ {
+		if x := i >> (8*n - 1); x == 0 || x == -1 {
+			break
+		}
+	}
+	for k := n - 1; k >= 0; k-- {
+		b = append(b, byte(i>>(8*k)))
+	}
+	return b
+}
+
+// AppendUint appends the shortest big-endian representation of u to b
+// based on https://www.rfc-editor.org/rfc/rfc8794.html#section-7.2
+func AppendUint(b []byte, u uint64) []byte {
+	n := max((bits.Len64(u)+7)/8, 1)
+	for k := n - 1; k >= 0; k-- {
+		b = append(b, byte(u>>(8*k)))
+	}
+	return b
+}
+
+// AppendFloat appends the representation of f to b using size octets
+// based on https://www.rfc-editor.org/rfc/rfc8794.html#section-7.3
+//
+// The size must be 4 or 8.
+func AppendFloat(b []byte, f float64, size int) []byte {
+	if size == 4 {
+		return binary.BigEndian.AppendUint32(b, math.Float32bits(float32(f)))
+	}
+	return binary.BigEndian.AppendUint64(b, math.Float64bits(f))
+}
+
+// AppendDate appends the representation of t to b based on
+// https://www.rfc-editor.org/rfc/rfc8794.html#section-7.6
+//
+// A date can only be about 292 years away from 2001-01-01T00:00:00 UTC;
+// t is clamped to that range.
+func AppendDate(b []byte, t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(b, uint64(t.Sub(thirdMillennium)))
+}
+
 type Encoder struct {
 	// https://datatracker.ietf.org/doc/html/rfc8794#section-11.2.4
 	MaxIDLength uint
@@ -238,9 +330,14 @@ func (e *Encoder) WriteElementDataSize(ds int64, minW int) (n int, err error) {
 	e.buf = e.buf[:e.MaxSizeLength]
 	uds := uint64(ds)
 	if ds == -1 {
+		minW = max(minW, 1)
 		uds = makeVintDataAllOne(minW)
 	}
 	w, err := AppendVintData(uds, minW, e.buf)
+	if err == nil && ds != -1 && uds == makeVintDataAllOne(w) {
+		// The VINT_DATA with all bits set to one is reserved for the unknown size.
+		w, err = AppendVintData(uds, w+1, e.buf)
+	}
 	e.offset += int64(w)
 	if err != nil {
 		return 0, ErrInvalidVINTWidth
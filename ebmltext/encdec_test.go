@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"github.com/coding-socks/ebml/schema"
 	"io"
+	"math"
 	"testing"
 )
 
@@ -46,3 +47,153 @@ func TestCode(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestParseElementDataSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       []byte
+		want    int64
+		wantW   int
+		wantErr error
+	}{
+		{name: "1 byte", b: []byte{0x81}, want: 1, wantW: 1},
+		{name: "2 bytes", b: []byte{0x40, 0x02}, want: 2, wantW: 2},
+		{name: "unknown 1 byte", b: []byte{0xff}, want: -1, wantW: 1},
+		{name: "unknown 2 bytes", b: []byte{0x7f, 0xff}, want: -1, wantW: 2},
+		{name: "too wide", b: []byte{0x00, 0x00, 0x01}, wantErr: ErrInvalidVINTWidth},
+		{name: "short", b: []byte{0x40}, wantErr: ErrInvalidVINTWidth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, w, err := ParseElementDataSize(tt.b, 2)
+			if err != tt.wantErr {
+				t.Fatalf("ParseElementDataSize() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want || w != tt.wantW {
+				t.Errorf("ParseElementDataSize() = %d, %d, want %d, %d", got, w, tt.want, tt.wantW)
+			}
+		})
+	}
+}
+
+func TestEncoder_WriteElementDataSize_unknown(t *testing.T) {
+	for w := 1; w <= 8; w++ {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		if _, err := enc.WriteElementDataSize(-1, w); err != nil {
+			t.Fatal(err)
+		}
+		got, gotW, err := ParseElementDataSize(buf.Bytes(), 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != -1 || gotW != w {
+			t.Errorf("width %d: ParseElementDataSize() = %d, %d, want -1, %d", w, got, gotW, w)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := NewEncoder(&buf).WriteElementDataSize(-1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), []byte{0xff}) {
+		t.Errorf("WriteElementDataSize(-1, 0) = %x, want ff", buf.Bytes())
+	}
+}
+
+func TestEncoder_WriteElementDataSize_reserved(t *testing.T) {
+	tests := []struct {
+		ds   int64
+		minW int
+		want []byte
+	}{
+		{ds: 126, want: []byte{0xfe}},
+		{ds: 127, want: []byte{0x40, 0x7f}},
+		{ds: 1<<14 - 1, want: []byte{0x20, 0x3f, 0xff}},
+		{ds: 127, minW: 2, want: []byte{0x40, 0x7f}},
+		{ds: 5, minW: 8, want: []byte{0x01, 0, 0, 0, 0, 0, 0, 5}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if _, err := NewEncoder(&buf).WriteElementDataSize(tt.ds, tt.minW); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("WriteElementDataSize(%d, %d) = %x, want %x", tt.ds, tt.minW, buf.Bytes(), tt.want)
+		}
+	}
+}
+
+func TestAppendInt(t *testing.T) {
+	tests := []struct {
+		i    int64
+		want []byte
+	}{
+		{i: 0, want: []byte{0x00}},
+		{i: 127, want: []byte{0x7f}},
+		{i: 128, want: []byte{0x00, 0x80}},
+		{i: -1, want: []byte{0xff}},
+		{i: -128, want: []byte{0x80}},
+		{i: -129, want: []byte{0xff, 0x7f}},
+		{i: math.MinInt64, want: []byte{0x80, 0, 0, 0, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		got := AppendInt(nil, tt.i)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("AppendInt(%d) = %x, want %x", tt.i, got, tt.want)
+		}
+		if i, err := Int(got); err != nil || i != tt.i {
+			t.Errorf("Int(%x) = %d, %v, want %d", got, i, err, tt.i)
+		}
+	}
+}
+
+func TestAppendUint(t *testing.T) {
+	tests := []struct {
+		u    uint64
+		want []byte
+	}{
+		{u: 0, want: []byte{0x00}},
+		{u: 0xff, want: []byte{0xff}},
+		{u: 0x100, want: []byte{0x01, 0x00}},
+		{u: math.MaxUint64, want: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		got := AppendUint(nil, tt.u)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("AppendUint(%d) = %x, want %x", tt.u, got, tt.want)
+		}
+		if u, err := Uint(got); err != nil || u != tt.u {
+			t.Errorf("Uint(%x) = %d, %v, want %d", got, u, err, tt.u)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		b    []byte
+		want string
+	}{
+		{b: nil, want: ""},
+		{b: []byte("abc"), want: "abc"},
+		{b: []byte("abc\x00\x00"), want: "abc"},
+		{b: []byte("a\x00bc"), want: "a"},
+	}
+	for _, tt := range tests {
+		if got, err := String(tt.b); err != nil || got != tt.want {
+			t.Errorf("String(%q) = %q, %v, want %q", tt.b, got, err, tt.want)
+		}
+	}
+}
+
+func TestDate(t *testing.T) {
+	for _, n := range []int{0, 8} {
+		if _, err := Date(make([]byte, n)); err != nil {
+			t.Errorf("Date(%d octets) error = %v", n, err)
+		}
+	}
+	for _, n := range []int{1, 4, 9} {
+		if _, err := Date(make([]byte, n)); err == nil {
+			t.Errorf("Date(%d octets) error = nil", n)
+		}
+	}
+}
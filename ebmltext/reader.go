@@ -28,6 +28,8 @@ func (b *byteReader) window() []byte {
 const (
 	newBufferSize = 1024
 	minReadSize   = 16
+
+	maxConsecutiveEmptyReads = 100
 )
 
 // extend extends the window with data from the underlying reader.
@@ -59,6 +61,28 @@ func (b *byteReader) extend() int {
 	return n
 }
 
+// peek extends the window until it holds at least n bytes or the
+// underlying reader returns an error.
+func (b *byteReader) peek(n int) []byte {
+	for empty := 0; len(b.data)-b.offset < n && b.err == nil; {
+		if cap(b.data)-b.offset < n {
+			buf := make([]byte, len(b.data)-b.offset, max(n, cap(b.data)*2, newBufferSize))
+			copy(buf, b.data[b.offset:])
+			b.data = buf
+			b.offset = 0
+		}
+		m, err := b.r.Read(b.data[len(b.data):cap(b.data)])
+		b.data = b.data[:len(b.data)+m]
+		b.err = err
+		if m == 0 && err == nil {
+			if empty++; empty >= maxConsecutiveEmptyReads {
+				b.err = io.ErrNoProgress
+			}
+		}
+	}
+	return b.window()
+}
+
 // grow grows the buffer, moving the active data to the front.
 func (b *byteReader) grow() {
 	buf := make([]byte, max(cap(b.data)*2, newBufferSize))
@@ -36,21 +36,21 @@ func AppendVintData(num uint64, minW int, b []byte) (w int, err error) {
 
 // ReadVint reads the integer representation of a VINT from b based on
 // https://datatracker.ietf.org/doc/html/rfc8794.html#section-4
+//
+// A VINT is at most 8 octets long, so a first octet without VINT_MARKER
+// returns ErrInvalidVINTWidth.
 func ReadVint(b []byte) (vint uint64, w int, err error) {
-	w = 1
-	n := len(b)
-	for i := 0; i < n; i++ {
-		x := bits.LeadingZeros8(b[i])
-		w += x
-		if x < 8 {
-			break
-		}
+	if len(b) == 0 {
+		return 0, 0, io.ErrShortBuffer
+	}
+	if b[0] == 0 {
+		return 0, 0, ErrInvalidVINTWidth
 	}
-	if w > n {
+	w = bits.LeadingZeros8(b[0]) + 1
+	if w > len(b) {
 		return 0, 0, io.ErrShortBuffer
 	}
-	offset := w / 8
-	for i := w - 1; i >= offset; i-- {
+	for i := 0; i < w; i++ {
 		// big endian
 		vint |= uint64(b[i]) << ((w - i - 1) * 8)
 	}
@@ -69,14 +69,15 @@ func ReadVintData(b []byte) (vint uint64, w int, err error) {
 }
 
 func vintAllOne(vint uint64) bool {
-	w := ((bits.Len64(vint) - 1) / 7) + 1
+	w := max((bits.Len64(vint)+7)/8, 1)
 	return vintDataAllOne(vint, w)
 }
 
 func vintDataAllOne(vint uint64, w int) bool {
-	return bits.OnesCount64(vint<<((8*w)-w)) == ((8 * w) - w)
+	mask := makeVintDataAllOne(w)
+	return vint&mask == mask
 }
 
 func makeVintDataAllOne(w int) uint64 {
-	return (1 << ((w * 8) - w + 1)) - 1
+	return (1 << ((w * 8) - w)) - 1
 }
@@ -100,3 +100,30 @@ func Test_vint(t *testing.T) {
 		})
 	}
 }
+
+func FuzzReadVint(f *testing.F) {
+	for _, seed := range [][]byte{{}, {0x82}, {0x40, 0x02}, {0x01, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11}, {0xff}, {0, 0, 1}} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		vint, w, err := ReadVint(b)
+		if err != nil {
+			return
+		}
+		if w < 1 || w > 8 || w > len(b) {
+			t.Fatalf("ReadVint(%x) width = %d", b, w)
+		}
+		data, dw, err := ReadVintData(b)
+		if err != nil || dw != w {
+			t.Fatalf("ReadVintData(%x) = %d, %v, want width %d", b, dw, err, w)
+		}
+		buf := make([]byte, 8)
+		n, err := AppendVintData(data, w, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[:n], b[:w]) {
+			t.Errorf("AppendVintData(%d, %d) = %x, want %x (vint %x)", data, w, buf[:n], b[:w], vint)
+		}
+	})
+}
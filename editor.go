@@ -0,0 +1,313 @@
+package ebml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/coding-socks/ebml/ebmltext"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrRelayoutRequired signals that a new value does not fit into the
+// space of the element and the document has to be written again.
+var ErrRelayoutRequired = errors.New("ebml: the element does not fit in place, a relayout is required")
+
+// An Editor changes the values of elements in place, without moving
+// the rest of the document. It is meant for updating a few values of
+// a large document.
+//
+// The elements of a document are located with Find, and their values
+// are replaced with the Set methods. A value is written in place when its
+// encoding fits into the space of the element, which includes the slack
+// of the data size and an adjacent Void element. The remaining space is
+// filled with a Void element. The data sizes of the parents stay the
+// same, and the CRC-32 elements of the parents are computed again.
+type Editor struct {
+	rws  io.ReadWriteSeeker
+	def  *Def
+	size int64
+
+	maxIDLength   uint
+	maxSizeLength uint
+
+	// ends holds the end of the parent of the elements returned by Find.
+	ends map[int64]int64
+	// parents holds the data of the parents of the elements returned by
+	// Find, from the innermost one.
+	parents map[int64][]*dataRange
+	buf     []byte
+}
+
+// A dataRange is the data of a master element in the document.
+type dataRange struct {
+	start, end int64
+}
+
+// NewEditor reads the EBML Header of rws and returns an Editor for the
+// document.
+func NewEditor(rws io.ReadWriteSeeker) (*Editor, error) {
+	size, err := rws.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rws.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	h, err := NewDecoder(rws).DecodeHeader()
+	if err != nil {
+		return nil, err
+	}
+	def, err := Definition(h.DocType)
+	if err != nil {
+		return nil, err
+	}
+	return &Editor{
+		rws:  rws,
+		def:  def,
+		size: size,
+
+		maxIDLength:   h.EBMLMaxIDLength,
+		maxSizeLength: h.EBMLMaxSizeLength,
+
+		ends:    make(map[int64]int64),
+		parents: make(map[int64][]*dataRange),
+	}, nil
+}
+
+// Find returns the elements with the given schema path, such as
+// `\Segment\Info\Title`, in the order of the document. Only the masters
+// on the path, and the masters of unknown size, are read.
+func (ed *Editor) Find(path string) ([]Element, error) {
+	var found []Element
+	var visit func(parent *Element, parents []*dataRange, start, end int64) (int64, error)
+	visit = func(parent *Element, parents []*dataRange, start, end int64) (int64, error) {
+		off := start
+		for off < end {
+			el, err := ed.readHeader(off)
+			if err != nil {
+				return 0, err
+			}
+			if parent != nil && parent.DataSize == -1 && !isChild(*parent, el) {
+				return off, nil
+			}
+			dataStart := off + int64(el.HeaderSize)
+			if el.Schema.Path == path {
+				ed.ends[el.Offset] = end
+				ed.parents[el.Offset] = parents
+				found = append(found, el)
+			}
+			switch {
+			case el.DataSize == -1:
+				if el.Schema.Type != TypeMaster {
+					return 0, newElementError(el, ErrUnknownSizeNotAllowed)
+				}
+				r := &dataRange{start: dataStart}
+				if off, err = visit(&el, append([]*dataRange{r}, parents...), dataStart, end); err != nil {
+					return 0, err
+				}
+				r.end = off
+				continue
+			case el.Schema.Type == TypeMaster && strings.HasPrefix(path, el.Schema.Path+`\`):
+				r := &dataRange{start: dataStart, end: min(dataStart+el.DataSize, end)}
+				if _, err := visit(&el, append([]*dataRange{r}, parents...), r.start, r.end); err != nil {
+					return 0, err
+				}
+			}
+			off = dataStart + el.DataSize
+		}
+		return end, nil
+	}
+	_, err := visit(nil, nil, 0, ed.size)
+	return found, err
+}
+
+// readHeader reads the header of the element at offset off.
+func (ed *Editor) readHeader(off int64) (Element, error) {
+	if _, err := ed.rws.Seek(off, io.SeekStart); err != nil {
+		return Element{}, err
+	}
+	b := ed.scratch(int(ed.maxIDLength + ed.maxSizeLength))
+	n, err := io.ReadFull(ed.rws, b)
+	if n == 0 || err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Element{}, &SyntaxError{Offset: off, Err: err}
+	}
+	b = b[:n]
+	id, idW, err := ebmltext.ParseElementID(b, ed.maxIDLength)
+	if err != nil {
+		return Element{}, &SyntaxError{Offset: off, Err: err}
+	}
+	ds, sizeW, err := ebmltext.ParseElementDataSize(b[idW:], ed.maxSizeLength)
+	if err != nil {
+		return Element{}, &SyntaxError{Offset: off + int64(idW), Err: err}
+	}
+	el := Element{ID: id, DataSize: ds, Offset: off, HeaderSize: idW + sizeW}
+	el.Schema, _ = ed.def.Get(id)
+	return el, nil
+}
+
+func (ed *Editor) scratch(n int) []byte {
+	if cap(ed.buf) < n {
+		ed.buf = make([]byte, n)
+	}
+	return ed.buf[:n]
+}
+
+// SetData replaces the data of el, which was returned by Find, with data.
+// It returns an error wrapping ErrRelayoutRequired when data does not fit.
+func (ed *Editor) SetData(el Element, data []byte) error {
+	if el.DataSize == -1 {
+		return newElementError(el, ErrUnknownSizeNotAllowed)
+	}
+	avail := int64(el.HeaderSize) + el.DataSize
+	if end, ok := ed.ends[el.Offset]; ok && el.Offset+avail < end {
+		next, err := ed.readHeader(el.Offset + avail)
+		if err == nil && next.ID == IDVoid && next.DataSize != -1 && next.Offset+int64(next.HeaderSize)+next.DataSize <= end {
+			avail += int64(next.HeaderSize) + next.DataSize
+		}
+	}
+
+	// Keep the width of the data size when possible, so the header does not change.
+	idW := len(ebmltext.AppendUint(nil, uint64(el.ID)))
+	for _, minW := range []int{el.HeaderSize - idW, 0} {
+		var b bytes.Buffer
+		enc := ebmltext.NewEncoder(&b)
+		enc.MaxIDLength, enc.MaxSizeLength = ed.maxIDLength, ed.maxSizeLength
+		if _, err := enc.WriteElementID(el.ID); err != nil {
+			return newElementError(el, err)
+		}
+		sizeW, err := enc.WriteElementDataSize(int64(len(data)), minW)
+		if err != nil {
+			continue
+		}
+		b.Write(data)
+		// A single remaining octet is taken by widening the data size.
+		if rest := avail - int64(b.Len()); rest < 0 || rest == 1 && sizeW == 8 {
+			continue
+		}
+		if err := OverwriteElement(seekWriterAt{ed.rws}, el.Offset, avail, b.Bytes()); err != nil {
+			return newElementError(el, err)
+		}
+		if err := ed.updateCRCs(ed.parents[el.Offset]); err != nil {
+			return newElementError(el, err)
+		}
+		return nil
+	}
+	return newElementError(el, fmt.Errorf("%w: %d octets available", ErrRelayoutRequired, avail))
+}
+
+// updateCRCs computes again the CRC-32 elements which start the data of
+// parents, from the innermost one, whose data covers the others.
+func (ed *Editor) updateCRCs(parents []*dataRange) error {
+	for _, r := range parents {
+		if r.start >= r.end {
+			continue
+		}
+		crc, err := ed.readHeader(r.start)
+		if err != nil {
+			return err
+		}
+		if crc.ID != IDCRC32 || crc.DataSize != 4 {
+			continue
+		}
+		dataStart := r.start + int64(crc.HeaderSize) + 4
+		if dataStart > r.end {
+			continue
+		}
+		h := crc32.NewIEEE()
+		if _, err := ed.rws.Seek(dataStart, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(h, ed.rws, r.end-dataStart); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		b := binary.LittleEndian.AppendUint32(nil, h.Sum32())
+		if _, err := (seekWriterAt{ed.rws}).WriteAt(b, dataStart-4); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetInteger replaces the value of the integer element el with i.
+func (ed *Editor) SetInteger(el Element, i int64) error {
+	if err := ed.checkType(el, TypeInteger); err != nil {
+		return err
+	}
+	return ed.SetData(el, ebmltext.AppendInt(nil, i))
+}
+
+// SetUinteger replaces the value of the unsigned integer element el with u.
+func (ed *Editor) SetUinteger(el Element, u uint64) error {
+	if err := ed.checkType(el, TypeUinteger); err != nil {
+		return err
+	}
+	return ed.SetData(el, ebmltext.AppendUint(nil, u))
+}
+
+// SetFloat replaces the value of the float element el with f. It keeps
+// four octets when the element has four octets and f fits into them.
+func (ed *Editor) SetFloat(el Element, f float64) error {
+	if err := ed.checkType(el, TypeFloat); err != nil {
+		return err
+	}
+	size := 8
+	if el.DataSize == 4 && float64(float32(f)) == f {
+		size = 4
+	}
+	return ed.SetData(el, ebmltext.AppendFloat(nil, f, size))
+}
+
+// SetString replaces the value of the string or utf-8 element el with s.
+func (ed *Editor) SetString(el Element, s string) error {
+	if el.Schema.Type != TypeUTF8 {
+		if err := ed.checkType(el, TypeString); err != nil {
+			return err
+		}
+	}
+	return ed.SetData(el, []byte(s))
+}
+
+// SetDate replaces the value of the date element el with t.
+func (ed *Editor) SetDate(el Element, t time.Time) error {
+	if err := ed.checkType(el, TypeDate); err != nil {
+		return err
+	}
+	return ed.SetData(el, ebmltext.AppendDate(nil, t))
+}
+
+// SetBinary replaces the value of the binary element el with b.
+func (ed *Editor) SetBinary(el Element, b []byte) error {
+	if err := ed.checkType(el, TypeBinary); err != nil {
+		return err
+	}
+	return ed.SetData(el, b)
+}
+
+func (ed *Editor) checkType(el Element, typ string) error {
+	if el.Schema.Type != typ {
+		return newElementError(el, fmt.Errorf("ebml: cannot set %s value of %s element", typ, el.Schema.Type))
+	}
+	return nil
+}
+
+// seekWriterAt implements io.WriterAt with an io.WriteSeeker.
+type seekWriterAt struct {
+	ws io.WriteSeeker
+}
+
+func (w seekWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if _, err := w.ws.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return w.ws.Write(p)
+}
@@ -0,0 +1,206 @@
+package ebml
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestEditorFile(t *testing.T, withVoid bool) *os.File {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	enc := NewEncoder(f)
+	if err := enc.EncodeHeader(&EBML{EBMLVersion: 1, EBMLReadVersion: 1, DocType: "test", DocTypeVersion: 1, DocTypeReadVersion: 1, EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8}); err != nil {
+		t.Fatal(err)
+	}
+	steps := []func() error{
+		func() error { return enc.StartMaster(testIDTest, AutoSize) },
+		func() error { return enc.StartMaster(testIDInfo, AutoSize) },
+		func() error { return enc.WriteString(testIDTitle, "abc") },
+		func() error {
+			if withVoid {
+				return enc.WriteVoid(20)
+			}
+			return nil
+		},
+		func() error { return enc.WriteUinteger(testIDTimestampScale, 1000) },
+		func() error { return enc.WriteDate(testIDDateUTC, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) },
+		enc.EndMaster,
+		func() error { return enc.Encode(testIDCluster, testCluster{Timestamp: 1}) },
+		func() error { return enc.Encode(testIDCluster, testCluster{Timestamp: 2}) },
+		enc.EndMaster,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+func decodeTestEditorFile(t *testing.T, f *os.File) testDocument {
+	t.Helper()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDecoder(f)
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var doc testDocument
+	if err := d.DecodeBody(&doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func findOne(t *testing.T, ed *Editor, path string) Element {
+	t.Helper()
+	els, err := ed.Find(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(els) != 1 {
+		t.Fatalf("Find(%q) = %d elements, want 1", path, len(els))
+	}
+	return els[0]
+}
+
+func TestEditor(t *testing.T) {
+	f := newTestEditorFile(t, false)
+	stat, _ := f.Stat()
+	ed, err := NewEditor(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ed.SetString(findOne(t, ed, `\Test\Info\Title`), "ab"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ed.SetUinteger(findOne(t, ed, `\Test\Info\TimestampScale`), 2000); err != nil {
+		t.Fatal(err)
+	}
+	timestamps, err := ed.Find(`\Test\Cluster\Timestamp`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timestamps) != 2 {
+		t.Fatalf("Find() = %d elements, want 2", len(timestamps))
+	}
+	if err := ed.SetUinteger(timestamps[1], 200); err != nil {
+		t.Fatal(err)
+	}
+	if err := ed.SetUinteger(findOne(t, ed, `\EBML\DocTypeVersion`), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	title := findOne(t, ed, `\Test\Info\Title`)
+	if err := ed.SetString(title, "too long"); !errors.Is(err, ErrRelayoutRequired) {
+		t.Errorf("SetString() error = %v, want ErrRelayoutRequired", err)
+	}
+	if err := ed.SetUinteger(title, 1); err == nil {
+		t.Error("SetUinteger() on utf-8 element error = nil")
+	}
+
+	if after, _ := f.Stat(); after.Size() != stat.Size() {
+		t.Errorf("size = %d, want %d", after.Size(), stat.Size())
+	}
+	got := decodeTestEditorFile(t, f)
+	want := testDocument{
+		Info: testInfo{Title: "ab", TimestampScale: 2000, DateUTC: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Offset: -1},
+		Cluster: []testCluster{
+			{Timestamp: 1},
+			{Timestamp: 200},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("document = %+v, want %+v", got, want)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewDecoder(f).DecodeHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.DocTypeVersion != 3 {
+		t.Errorf("DocTypeVersion = %d, want 3", h.DocTypeVersion)
+	}
+}
+
+func TestEditor_void(t *testing.T) {
+	f := newTestEditorFile(t, true)
+	ed, err := NewEditor(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ed.SetString(findOne(t, ed, `\Test\Info\Title`), "a longer title"); err != nil {
+		t.Fatal(err)
+	}
+	got := decodeTestEditorFile(t, f)
+	if got.Info.Title != "a longer title" || got.Info.TimestampScale != 1000 {
+		t.Errorf("Info = %+v", got.Info)
+	}
+
+	// The Void element is used again when the value shrinks.
+	if err := ed.SetString(findOne(t, ed, `\Test\Info\Title`), "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ed.SetString(findOne(t, ed, `\Test\Info\Title`), "a longer title"); err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeTestEditorFile(t, f); got.Info.Title != "a longer title" {
+		t.Errorf("Title = %q", got.Info.Title)
+	}
+}
+
+func TestEditor_crc(t *testing.T) {
+	title := testElement(testIDTitle, []byte("abc"))
+	info := testElement(testIDInfo, testCRC(title), title)
+	cluster := testUnknownSizeElement(testIDCluster, testElement(testIDTimestamp, []byte{1}))
+	doc := append(testHeader("test"), testUnknownSizeElement(testIDTest, testCRC(info, cluster), info, cluster)...)
+	if report, err := Validate(bytes.NewReader(doc), ValidateOptions{}); err != nil || !report.Valid {
+		t.Fatalf("Validate(input) = %+v, %v", report, err)
+	}
+
+	f, err := os.CreateTemp(t.TempDir(), "edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	if _, err := f.Write(doc); err != nil {
+		t.Fatal(err)
+	}
+	ed, err := NewEditor(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ed.SetString(findOne(t, ed, `\Test\Info\Title`), "xyz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ed.SetUinteger(findOne(t, ed, `\Test\Cluster\Timestamp`), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	report, err := Validate(f, ValidateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || len(report.Issues) != 0 {
+		t.Errorf("Validate() = %+v, want a valid report without issues", report)
+	}
+	if got := decodeTestEditorFile(t, f); got.Info.Title != "xyz" || got.Cluster[0].Timestamp != 2 {
+		t.Errorf("document = %+v", got)
+	}
+}
@@ -0,0 +1,762 @@
+package ebml
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/coding-socks/ebml/ebmltext"
+	"github.com/coding-socks/ebml/schema"
+	"hash/crc32"
+	"io"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+// An EncodeTypeError describes a Go value that cannot be encoded
+// as a specific EBML type.
+type EncodeTypeError struct {
+	EBMLType   string           // description of EBML type - "integer", "binary", "master"
+	Type       reflect.Type     // type of Go value it could not be encoded from
+	ID         schema.ElementID // ID of the element
+	SchemaPath string           // path of the element in the schema
+	Path       string           // the full path from root node to the field
+}
+
+func (e *EncodeTypeError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("ebml: cannot marshal Go struct field %s of type %s into %s", e.Path, e.Type, e.EBMLType)
+	}
+	return fmt.Sprintf("ebml: cannot marshal Go value of type %s into %s", e.Type, e.EBMLType)
+}
+
+func (e *EncodeTypeError) extendError(p string) {
+	if e.Path == "" {
+		e.Path = p
+		return
+	}
+	e.Path = p + "." + e.Path
+}
+
+func newEncodeTypeError(v reflect.Value, def schema.Element) *EncodeTypeError {
+	return &EncodeTypeError{EBMLType: def.Type, Type: v.Type(), ID: def.ID, SchemaPath: def.Path}
+}
+
+// An UndefinedElementError describes an Element ID which is not
+// defined by the schema of the Encoder.
+type UndefinedElementError struct {
+	ID schema.ElementID
+}
+
+func (e *UndefinedElementError) Error() string {
+	return fmt.Sprintf("ebml: element %v is not defined by the schema", e.ID)
+}
+
+// An EncodeElementError describes an element which cannot be written
+// at the current position of the Encoder.
+type EncodeElementError struct {
+	ID         schema.ElementID // ID of the element
+	SchemaPath string           // path of the element in the schema
+	Err        error
+}
+
+func (e *EncodeElementError) Error() string {
+	name := e.SchemaPath
+	if name == "" {
+		name = e.ID.String()
+	}
+	return fmt.Sprintf("ebml: cannot write element %s: %v", name, e.Err)
+}
+
+func (e *EncodeElementError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// ErrInvalidTermination means that an element would be read as part
+	// of an unknown-sized element instead of ending it, or that it would
+	// end an unknown-sized element it is written into.
+	ErrInvalidTermination = errors.New("ebml: element does not legally terminate the unknown-sized element")
+
+	// ErrDataSizeMismatch means that the children written into a master
+	// element do not add up to the data size passed to StartMaster.
+	ErrDataSizeMismatch = errors.New("ebml: written data does not match the data size")
+
+	// ErrNoOpenMaster means that EndMaster was called without a master
+	// element started by StartMaster.
+	ErrNoOpenMaster = errors.New("ebml: no master element started by StartMaster")
+)
+
+const (
+	// UnknownSize is the data size of a master element which ends where
+	// the following element is not one of its children.
+	UnknownSize int64 = -1
+	// AutoSize is the data size of a master element which is computed
+	// when the element ends.
+	AutoSize int64 = -2
+)
+
+// Marshaler is the interface implemented by types that can encode the
+// children of a master element themselves, without reflection.
+//
+// EncodeEBML is called after the Encoder started the master element.
+// It is expected to write the children, typically with the Write
+// methods of e.
+type Marshaler interface {
+	EncodeEBML(e *Encoder) error
+}
+
+// An Encoder writes an EBML Document to an output stream.
+type Encoder struct {
+	w   *ebmltext.Encoder
+	out io.Writer
+	def *Def
+
+	// masters holds the master elements being written.
+	masters []openMaster
+	free    []*bytes.Buffer
+	buf     []byte
+	// cbuf holds the canonical form of the data of an element.
+	cbuf      []byte
+	canonical bool
+	// written is the number of octets written to out.
+	written int64
+	// seeker is out when it is able to seek, and base is its offset
+	// when the Encoder was created.
+	seeker io.WriteSeeker
+	base   int64
+
+	// ended is the last unknown-sized element ended by EndMaster,
+	// waiting for the element which terminates it.
+	ended      *schema.Element
+	endedDepth int
+}
+
+// openMaster is a master element being written.
+type openMaster struct {
+	sch schema.Element
+	// explicit reports whether the element was started by StartMaster.
+	explicit bool
+	// buf holds the children until the data size is known. It is nil
+	// when the header is written as the element starts.
+	buf *bytes.Buffer
+	// size is the data size of the element, or AutoSize when the data
+	// size is patched as the element ends.
+	size int64
+	// sizeOffset is the offset in out of the data size to patch.
+	sizeOffset int64
+	// start is the length of the sink after the header.
+	start int64
+	// crcEnd is the end of the CRC-32 element at the start of buf, or 0
+	// when there is none.
+	crcEnd int
+}
+
+// NewEncoder returns a new encoder that writes to w.
+//
+// When w is an io.WriteSeeker able to seek, the data size of a master
+// element is reserved with EBMLMaxSizeLength octets as the element starts,
+// and it is patched as the element ends. Otherwise, the children are
+// buffered until the data size is known.
+func NewEncoder(w io.Writer) *Encoder {
+	e := &Encoder{
+		out: w,
+		def: HeaderDef,
+	}
+	if ws, ok := w.(io.WriteSeeker); ok {
+		if off, err := ws.Seek(0, io.SeekCurrent); err == nil {
+			e.seeker, e.base = ws, off
+		}
+	}
+	e.w = ebmltext.NewEncoder(encoderSink{e: e})
+	e.w.MaxIDLength = DefaultMaxIDLength
+	e.w.MaxSizeLength = DefaultMaxSizeLength
+	return e
+}
+
+// encoderSink writes to the innermost open master element, or to the
+// output stream when there is none.
+type encoderSink struct {
+	e *Encoder
+}
+
+func (s encoderSink) Write(p []byte) (int, error) {
+	if buf := s.e.sink(); buf != nil {
+		return buf.Write(p)
+	}
+	n, err := s.e.out.Write(p)
+	s.e.written += int64(n)
+	return n, err
+}
+
+// sink returns the buffer of the innermost buffered master element, or
+// nil when the output stream is written directly.
+func (e *Encoder) sink() *bytes.Buffer {
+	for i := len(e.masters) - 1; i >= 0; i-- {
+		if buf := e.masters[i].buf; buf != nil {
+			return buf
+		}
+	}
+	return nil
+}
+
+// sinkLen returns the number of octets written to the current sink.
+func (e *Encoder) sinkLen() int64 {
+	if buf := e.sink(); buf != nil {
+		return int64(buf.Len())
+	}
+	return e.written
+}
+
+// OutputOffset returns the offset in the output stream of the next
+// element. It returns -1 when the data of the current master element is
+// buffered, as its position is only known when it ends.
+func (e *Encoder) OutputOffset() int64 {
+	if e.sink() != nil {
+		return -1
+	}
+	return e.base + e.written
+}
+
+// EncodeHeader writes the EBML Header h and selects the definition
+// registered for h.DocType to encode the EBML Body.
+func (e *Encoder) EncodeHeader(h *EBML) error {
+	def, err := Definition(h.DocType)
+	if err != nil {
+		return err
+	}
+	e.def = HeaderDef
+	e.w.MaxIDLength = DefaultMaxIDLength
+	e.w.MaxSizeLength = DefaultMaxSizeLength
+	if err := e.WriteMaster(IDEBML, h); err != nil {
+		return err
+	}
+	e.def = def
+	if h.EBMLMaxIDLength != 0 {
+		e.w.MaxIDLength = h.EBMLMaxIDLength
+	}
+	if h.EBMLMaxSizeLength != 0 {
+		e.w.MaxSizeLength = h.EBMLMaxSizeLength
+	}
+	return nil
+}
+
+// EncodeBody writes v as the EBML Root Element.
+func (e *Encoder) EncodeBody(v any) error {
+	return e.Encode(e.def.Root.ID, v)
+}
+
+// Encode writes v as the element with the given id.
+//
+// Master elements are written from the fields of a struct, matched by
+// name or ID like Decoder.Decode does, unless v implements Marshaler.
+// Nil pointers and empty fields with the omitempty option are omitted,
+// and every item of a slice is written as a separate element.
+func (e *Encoder) Encode(id schema.ElementID, v any) error {
+	sch, ok := e.def.Get(id)
+	if !ok {
+		return &UndefinedElementError{ID: id}
+	}
+	val := reflect.ValueOf(v)
+	if !val.IsValid() {
+		return nil
+	}
+	if val.Kind() != reflect.Ptr {
+		// Make the value addressable to find methods with pointer receivers.
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		val = ptr
+	}
+	err := e.encodeValue(sch, val)
+	if err != nil && val.Elem().Kind() == reflect.Struct {
+		if te, ok := err.(*EncodeTypeError); ok {
+			te.extendError(val.Elem().Type().Name())
+		}
+	}
+	return err
+}
+
+// WriteMaster writes v as the master element with the given id. The
+// children are written by v when it implements Marshaler, otherwise
+// WriteMaster behaves like Encode.
+func (e *Encoder) WriteMaster(id schema.ElementID, v any) error {
+	m, ok := v.(Marshaler)
+	if !ok {
+		return e.Encode(id, v)
+	}
+	sch, ok := e.def.Get(id)
+	if !ok {
+		return &UndefinedElementError{ID: id}
+	}
+	if err := e.startMaster(sch, AutoSize, false); err != nil {
+		return err
+	}
+	return e.endMaster(m.EncodeEBML(e))
+}
+
+// StartMaster writes the header of the master element with the given id
+// and data size. The following elements are written into it until the
+// matching call of EndMaster.
+//
+// With UnknownSize, the children are streamed without knowing their size
+// in advance. The schema must allow the element to be of unknown size.
+// The Encoder ensures that the elements written into it are its children,
+// and that the element written after EndMaster terminates it.
+//
+// With AutoSize, the data size is computed by EndMaster. With a known
+// size, EndMaster reports an error if the children do not add up to size.
+func (e *Encoder) StartMaster(id schema.ElementID, size int64) error {
+	sch, err := e.masterSchema(id, size)
+	if err != nil {
+		return err
+	}
+	return e.startMaster(sch, size, true)
+}
+
+// masterSchema returns the schema of the master element with the given
+// id, after checking that it can be started with size.
+func (e *Encoder) masterSchema(id schema.ElementID, size int64) (schema.Element, error) {
+	sch, ok := e.def.Get(id)
+	if !ok {
+		return sch, &UndefinedElementError{ID: id}
+	}
+	if sch.Type != TypeMaster {
+		return sch, &EncodeElementError{ID: id, SchemaPath: sch.Path, Err: fmt.Errorf("ebml: element of type %s is not a master", sch.Type)}
+	}
+	if size < AutoSize {
+		return sch, &EncodeElementError{ID: id, SchemaPath: sch.Path, Err: fmt.Errorf("ebml: invalid data size %d", size)}
+	}
+	if size == UnknownSize && !sch.UnknownSizeAllowed {
+		return sch, &EncodeElementError{ID: id, SchemaPath: sch.Path, Err: ErrUnknownSizeNotAllowed}
+	}
+	return sch, nil
+}
+
+// startMasterCRC is StartMaster with AutoSize, which also writes a CRC-32
+// element as the first child. The CRC-32 is computed over the following
+// children by EndMaster.
+func (e *Encoder) startMasterCRC(id schema.ElementID) error {
+	sch, err := e.masterSchema(id, AutoSize)
+	if err != nil {
+		return err
+	}
+	if err := e.begin(sch.ID); err != nil {
+		return err
+	}
+	m := openMaster{sch: sch, explicit: true, size: AutoSize, buf: e.buffer()}
+	e.masters = append(e.masters, m)
+	if err := e.writeData(IDCRC32, make([]byte, 4)); err != nil {
+		return err
+	}
+	e.masters[len(e.masters)-1].crcEnd = m.buf.Len()
+	return nil
+}
+
+// EndMaster ends the innermost master element started by StartMaster.
+func (e *Encoder) EndMaster() error {
+	if n := len(e.masters); n == 0 || !e.masters[n-1].explicit {
+		return ErrNoOpenMaster
+	}
+	return e.closeMaster()
+}
+
+// begin checks that the element with the given id can start at the
+// current position.
+func (e *Encoder) begin(id schema.ElementID) error {
+	el := Element{ID: id}
+	el.Schema, _ = e.def.Get(id)
+	if e.canonical && !isCanonicalID(id) {
+		return &EncodeElementError{ID: id, SchemaPath: el.Schema.Path, Err: fmt.Errorf("%w: element ID has a shorter form", ErrNotCanonical)}
+	}
+	if ended := e.ended; ended != nil && e.endedDepth == len(e.masters) {
+		e.ended = nil
+		if el.Schema.Name == UnknownSchema.Name || isChild(Element{ID: ended.ID, Schema: *ended}, el) {
+			return &EncodeElementError{ID: id, SchemaPath: el.Schema.Path, Err: ErrInvalidTermination}
+		}
+	}
+	if n := len(e.masters); n > 0 {
+		m := e.masters[n-1]
+		if m.buf == nil && m.size == UnknownSize && !isChild(Element{ID: m.sch.ID, Schema: m.sch}, el) {
+			return &EncodeElementError{ID: id, SchemaPath: el.Schema.Path, Err: ErrInvalidTermination}
+		}
+	}
+	return nil
+}
+
+// WriteInteger writes a signed integer element.
+func (e *Encoder) WriteInteger(id schema.ElementID, i int64) error {
+	e.buf = ebmltext.AppendInt(e.buf[:0], i)
+	return e.writeElement(id, e.buf)
+}
+
+// WriteUinteger writes an unsigned integer element.
+func (e *Encoder) WriteUinteger(id schema.ElementID, u uint64) error {
+	e.buf = ebmltext.AppendUint(e.buf[:0], u)
+	return e.writeElement(id, e.buf)
+}
+
+// WriteFloat writes a float element using eight octets.
+func (e *Encoder) WriteFloat(id schema.ElementID, f float64) error {
+	e.buf = ebmltext.AppendFloat(e.buf[:0], f, 8)
+	return e.writeElement(id, e.buf)
+}
+
+// WriteString writes a string or an utf-8 element.
+func (e *Encoder) WriteString(id schema.ElementID, s string) error {
+	e.buf = append(e.buf[:0], s...)
+	return e.writeElement(id, e.buf)
+}
+
+// WriteDate writes a date element.
+func (e *Encoder) WriteDate(id schema.ElementID, t time.Time) error {
+	e.buf = ebmltext.AppendDate(e.buf[:0], t)
+	return e.writeElement(id, e.buf)
+}
+
+// WriteBinary writes a binary element.
+func (e *Encoder) WriteBinary(id schema.ElementID, b []byte) error {
+	return e.writeElement(id, b)
+}
+
+func (e *Encoder) writeElement(id schema.ElementID, data []byte) error {
+	if sch, ok := e.def.Get(id); ok && e.canonical {
+		c, err := appendCanonical(e.cbuf[:0], sch.Type, data)
+		if err != nil {
+			return &EncodeElementError{ID: id, SchemaPath: sch.Path, Err: err}
+		}
+		e.cbuf = c
+		if isDefault(sch, c) {
+			return nil
+		}
+		data = c
+	}
+	if err := e.begin(id); err != nil {
+		return err
+	}
+	return e.writeData(id, data)
+}
+
+// writeData writes an element without checking its position.
+func (e *Encoder) writeData(id schema.ElementID, data []byte) error {
+	if _, err := e.w.WriteElementID(id); err != nil {
+		return err
+	}
+	if _, err := e.w.WriteElementDataSize(int64(len(data)), 0); err != nil {
+		return err
+	}
+	_, err := e.w.Write(data)
+	return err
+}
+
+// startMaster starts the master element sch with the given data size.
+// With AutoSize, the data size is reserved when out is able to seek,
+// otherwise the following writes are redirected into a buffer.
+func (e *Encoder) startMaster(sch schema.Element, size int64, explicit bool) error {
+	if err := e.begin(sch.ID); err != nil {
+		return err
+	}
+	m := openMaster{sch: sch, explicit: explicit, size: size}
+	if size == AutoSize && (e.seeker == nil || e.sink() != nil || e.canonical) {
+		m.buf = e.buffer()
+		e.masters = append(e.masters, m)
+		return nil
+	}
+	if _, err := e.w.WriteElementID(sch.ID); err != nil {
+		return err
+	}
+	if size == AutoSize {
+		m.sizeOffset = e.written
+		if _, err := e.w.WriteElementDataSize(0, int(e.w.MaxSizeLength)); err != nil {
+			return err
+		}
+	} else if _, err := e.w.WriteElementDataSize(size, 0); err != nil {
+		return err
+	}
+	m.start = e.sinkLen()
+	e.masters = append(e.masters, m)
+	return nil
+}
+
+// endMaster ends the innermost master element started by startMaster.
+// When err is not nil, the buffered data is discarded and err is returned.
+func (e *Encoder) endMaster(err error) error {
+	if n := len(e.masters); err == nil && e.masters[n-1].explicit {
+		m := e.masters[n-1]
+		err = &EncodeElementError{ID: m.sch.ID, SchemaPath: m.sch.Path, Err: errors.New("ebml: master element started by StartMaster is not ended")}
+	}
+	if err != nil {
+		for e.masters[len(e.masters)-1].explicit {
+			e.release(e.popMaster())
+		}
+		e.release(e.popMaster())
+		return err
+	}
+	return e.closeMaster()
+}
+
+// closeMaster writes the end of the innermost master element.
+func (e *Encoder) closeMaster() error {
+	m := e.popMaster()
+	e.ended = nil
+	switch {
+	case m.buf != nil:
+		defer e.release(m)
+		b := m.buf.Bytes()
+		if m.crcEnd > 0 {
+			binary.LittleEndian.PutUint32(b[m.crcEnd-4:], crc32.ChecksumIEEE(b[m.crcEnd:]))
+		}
+		return e.writeData(m.sch.ID, b)
+	case m.size == UnknownSize:
+		e.ended, e.endedDepth = &m.sch, len(e.masters)
+		return nil
+	case m.size == AutoSize:
+		return e.patchSize(m, e.written-m.start)
+	}
+	if written := e.sinkLen() - m.start; written != m.size {
+		return &EncodeElementError{ID: m.sch.ID, SchemaPath: m.sch.Path, Err: fmt.Errorf("%w: %d octets written, %d expected", ErrDataSizeMismatch, written, m.size)}
+	}
+	return nil
+}
+
+// popMaster removes the innermost master element.
+func (e *Encoder) popMaster() openMaster {
+	n := len(e.masters)
+	m := e.masters[n-1]
+	e.masters = e.masters[:n-1]
+	return m
+}
+
+// buffer returns an empty buffer for the children of a master element.
+func (e *Encoder) buffer() *bytes.Buffer {
+	if n := len(e.free); n > 0 {
+		buf := e.free[n-1]
+		e.free = e.free[:n-1]
+		return buf
+	}
+	return new(bytes.Buffer)
+}
+
+// release makes the buffer of m available to later master elements.
+func (e *Encoder) release(m openMaster) {
+	if m.buf != nil {
+		m.buf.Reset()
+		e.free = append(e.free, m.buf)
+	}
+}
+
+// patchSize overwrites the data size reserved for m with size.
+func (e *Encoder) patchSize(m openMaster, size int64) error {
+	w := int(e.w.MaxSizeLength)
+	if w > 8 || size >= 1<<(7*w)-1 {
+		return &EncodeElementError{ID: m.sch.ID, SchemaPath: m.sch.Path, Err: fmt.Errorf("ebml: data size %d does not fit into %d octets", size, w)}
+	}
+	b := make([]byte, w)
+	if _, err := ebmltext.AppendVintData(uint64(size), w, b); err != nil {
+		return err
+	}
+	if _, err := e.seeker.Seek(e.base+m.sizeOffset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := e.seeker.Write(b); err != nil {
+		return err
+	}
+	_, err := e.seeker.Seek(e.base+e.written, io.SeekStart)
+	return err
+}
+
+func (e *Encoder) encodeValue(sch schema.Element, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return e.encodeValue(sch, v.Elem())
+	case reflect.Slice:
+		if sch.Type == TypeBinary && v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encodeValue(sch, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if sch.Type == TypeMaster {
+		return e.encodeMaster(sch, v)
+	}
+	b, err := e.appendValue(e.buf[:0], sch, v)
+	if err != nil {
+		return err
+	}
+	e.buf = b
+	return e.writeElement(sch.ID, b)
+}
+
+func (e *Encoder) encodeMaster(sch schema.Element, v reflect.Value) error {
+	if v.CanAddr() {
+		if m, ok := v.Addr().Interface().(Marshaler); ok {
+			if err := e.startMaster(sch, AutoSize, false); err != nil {
+				return err
+			}
+			return e.endMaster(m.EncodeEBML(e))
+		}
+	}
+	if v.Kind() != reflect.Struct {
+		return newEncodeTypeError(v, sch)
+	}
+	tinfo, err := getTypeInfo(v.Type())
+	if err != nil {
+		return err
+	}
+	if err := e.startMaster(sch, AutoSize, false); err != nil {
+		return err
+	}
+	for i := range tinfo.fields {
+		finfo := &tinfo.fields[i]
+		fv, ferr := v.FieldByIndexErr(finfo.idx)
+		if ferr != nil {
+			continue // nil embedded pointer
+		}
+		if finfo.flags&fOmitEmpty != 0 && isEmptyValue(fv) {
+			continue
+		}
+		if finfo.flags&fUnknown != 0 {
+			if err = e.writeRaw(fv.Interface().([]RawElement)); err != nil {
+				break
+			}
+			continue
+		}
+		fsch, ok := e.fieldSchema(sch.Path, finfo)
+		if !ok {
+			// Like the Decoder, ignore fields without an element.
+			continue
+		}
+		if err = e.encodeValue(fsch, fv); err != nil {
+			if te, ok := err.(*EncodeTypeError); ok {
+				te.extendError(finfo.goName)
+			}
+			break
+		}
+	}
+	return e.endMaster(err)
+}
+
+// fieldSchema returns the element written for the field finfo of
+// the master element with the given path.
+func (e *Encoder) fieldSchema(path string, finfo *fieldInfo) (schema.Element, bool) {
+	if finfo.id != 0 {
+		if sch, ok := e.def.Get(finfo.id); ok {
+			return sch, true
+		}
+		return finfo.schema(path)
+	}
+	return e.def.childByName(path, finfo.name)
+}
+
+// writeRaw writes elements kept in their encoded form.
+func (e *Encoder) writeRaw(els []RawElement) error {
+	for _, el := range els {
+		if err := e.writeElement(el.ID, el.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) appendValue(b []byte, sch schema.Element, v reflect.Value) ([]byte, error) {
+	switch sch.Type {
+	case TypeInteger:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return ebmltext.AppendInt(b, v.Int()), nil
+		}
+
+	case TypeUinteger:
+		switch v.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return ebmltext.AppendUint(b, v.Uint()), nil
+		case reflect.Bool:
+			if v.Bool() {
+				return ebmltext.AppendUint(b, 1), nil
+			}
+			return ebmltext.AppendUint(b, 0), nil
+		}
+		if v.Type() == typeDuration && v.Int() >= 0 {
+			return ebmltext.AppendUint(b, uint64(v.Int())), nil
+		}
+
+	case TypeFloat:
+		switch v.Kind() {
+		case reflect.Float32:
+			return ebmltext.AppendFloat(b, v.Float(), 4), nil
+		case reflect.Float64:
+			return ebmltext.AppendFloat(b, v.Float(), 8), nil
+		}
+
+	case TypeString, TypeUTF8:
+		if m, ok := textMarshaler(v); ok {
+			text, err := m.MarshalText()
+			return append(b, text...), err
+		}
+		if v.Kind() == reflect.String {
+			return append(b, v.String()...), nil
+		}
+
+	case TypeDate:
+		if v.Type() == typeTime {
+			return ebmltext.AppendDate(b, v.Interface().(time.Time)), nil
+		}
+
+	case TypeBinary:
+		switch v.Type() {
+		case typeElementID:
+			return ebmltext.AppendUint(b, v.Uint()), nil
+		case typeBigInt:
+			if v.CanAddr() {
+				return append(b, v.Addr().Interface().(*big.Int).Bytes()...), nil
+			}
+		}
+		if m, ok := binaryMarshaler(v); ok {
+			data, err := m.MarshalBinary()
+			return append(b, data...), err
+		}
+		switch v.Kind() {
+		case reflect.String:
+			return append(b, v.String()...), nil
+		case reflect.Slice, reflect.Array:
+			if v.Type().Elem().Kind() == reflect.Uint8 && (v.Kind() == reflect.Slice || v.CanAddr()) {
+				return append(b, v.Bytes()...), nil
+			}
+		}
+	}
+	return nil, newEncodeTypeError(v, sch)
+}
+
+// binaryMarshaler returns the encoding.BinaryMarshaler implemented by v
+// or by the address of v.
+func binaryMarshaler(v reflect.Value) (encoding.BinaryMarshaler, bool) {
+	if m, ok := v.Interface().(encoding.BinaryMarshaler); ok {
+		return m, true
+	}
+	if !v.CanAddr() {
+		return nil, false
+	}
+	m, ok := v.Addr().Interface().(encoding.BinaryMarshaler)
+	return m, ok
+}
+
+// textMarshaler returns the encoding.TextMarshaler implemented by v
+// or by the address of v.
+func textMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		return m, true
+	}
+	if !v.CanAddr() {
+		return nil, false
+	}
+	m, ok := v.Addr().Interface().(encoding.TextMarshaler)
+	return m, ok
+}
@@ -0,0 +1,381 @@
+package ebml
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// reflectEBML has the fields of EBML without the generated methods.
+type reflectEBML struct {
+	EBMLVersion        uint
+	EBMLReadVersion    uint
+	EBMLMaxIDLength    uint
+	EBMLMaxSizeLength  uint
+	DocType            string
+	DocTypeVersion     uint
+	DocTypeReadVersion uint
+	DocTypeExtension   []struct {
+		DocTypeExtensionName    string
+		DocTypeExtensionVersion uint
+	}
+}
+
+var testEBML = EBML{
+	EBMLVersion:        1,
+	EBMLReadVersion:    1,
+	EBMLMaxIDLength:    4,
+	EBMLMaxSizeLength:  8,
+	DocType:            "test",
+	DocTypeVersion:     2,
+	DocTypeReadVersion: 1,
+	DocTypeExtension: []DocTypeExtension{
+		{DocTypeExtensionName: "ext", DocTypeExtensionVersion: 3},
+	},
+}
+
+func TestEncoder_EncodeHeader(t *testing.T) {
+	var generated bytes.Buffer
+	if err := NewEncoder(&generated).EncodeHeader(&testEBML); err != nil {
+		t.Fatal(err)
+	}
+
+	var reflected bytes.Buffer
+	r := reflectEBML{
+		EBMLVersion:        testEBML.EBMLVersion,
+		EBMLReadVersion:    testEBML.EBMLReadVersion,
+		EBMLMaxIDLength:    testEBML.EBMLMaxIDLength,
+		EBMLMaxSizeLength:  testEBML.EBMLMaxSizeLength,
+		DocType:            testEBML.DocType,
+		DocTypeVersion:     testEBML.DocTypeVersion,
+		DocTypeReadVersion: testEBML.DocTypeReadVersion,
+	}
+	r.DocTypeExtension = append(r.DocTypeExtension, struct {
+		DocTypeExtensionName    string
+		DocTypeExtensionVersion uint
+	}{"ext", 3})
+	if err := NewEncoder(&reflected).Encode(IDEBML, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated.Bytes(), reflected.Bytes()) {
+		t.Errorf("generated = %x, reflected = %x", generated.Bytes(), reflected.Bytes())
+	}
+
+	d := NewDecoder(bytes.NewReader(generated.Bytes()))
+	h, err := d.DecodeHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*h, testEBML) {
+		t.Errorf("DecodeHeader() = %+v, want %+v", *h, testEBML)
+	}
+
+	d = NewDecoder(bytes.NewReader(generated.Bytes()))
+	el, _, err := d.NextOf(RootEl, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got reflectEBML
+	if err := d.Decode(el, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("Decode() = %+v, want %+v", got, r)
+	}
+}
+
+func TestEncoder_EncodeBody(t *testing.T) {
+	want := testDocument{
+		Info: testInfo{
+			Title:          "title",
+			TimestampScale: 1000,
+			Duration:       12.5,
+			DateUTC:        time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Offset:         -300,
+		},
+		Cluster: []testCluster{
+			{Timestamp: 0, Payload: [][]byte{{1, 2}, {3}}},
+			{Timestamp: 1 << 40},
+		},
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.EncodeHeader(&EBML{DocType: "test", EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.EncodeBody(want); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(bytes.NewReader(buf.Bytes()))
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var got testDocument
+	if err := d.DecodeBody(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeBody() = %+v, want %+v", got, want)
+	}
+}
+
+func TestEncoder_StartMaster(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.EncodeHeader(&EBML{DocType: "test", EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.StartMaster(testIDTest, UnknownSize); err != nil {
+		t.Fatal(err)
+	}
+	info := testInfo{Title: "live", TimestampScale: 1000, DateUTC: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if err := enc.Encode(testIDInfo, info); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 2 {
+		if err := enc.StartMaster(testIDCluster, UnknownSize); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteUinteger(testIDTimestamp, uint64(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteBinary(testIDPayload, []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.EndMaster(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.StartMaster(testIDCluster, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteUinteger(testIDTimestamp, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.EndMaster(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.EndMaster(); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(bytes.NewReader(buf.Bytes()))
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var got testDocument
+	if err := d.DecodeBody(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := testDocument{
+		Info: info,
+		Cluster: []testCluster{
+			{Timestamp: 0, Payload: [][]byte{{0}}},
+			{Timestamp: 1, Payload: [][]byte{{1}}},
+			{Timestamp: 2},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeBody() = %+v, want %+v", got, want)
+	}
+}
+
+func TestEncoder_StartMaster_errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(enc *Encoder) error
+		want  error
+	}{
+		{name: "unknown size not allowed", want: ErrUnknownSizeNotAllowed, write: func(enc *Encoder) error {
+			return enc.StartMaster(testIDInfo, UnknownSize)
+		}},
+		{name: "not a master", write: func(enc *Encoder) error {
+			return enc.StartMaster(testIDTitle, UnknownSize)
+		}},
+		{name: "end without start", want: ErrNoOpenMaster, write: func(enc *Encoder) error {
+			return enc.EndMaster()
+		}},
+		{name: "size mismatch", want: ErrDataSizeMismatch, write: func(enc *Encoder) error {
+			if err := enc.StartMaster(testIDCluster, 10); err != nil {
+				return err
+			}
+			if err := enc.WriteUinteger(testIDTimestamp, 1); err != nil {
+				return err
+			}
+			return enc.EndMaster()
+		}},
+		{name: "not a child", want: ErrInvalidTermination, write: func(enc *Encoder) error {
+			if err := enc.StartMaster(testIDCluster, UnknownSize); err != nil {
+				return err
+			}
+			return enc.WriteString(testIDTitle, "title")
+		}},
+		{name: "child after end", want: ErrInvalidTermination, write: func(enc *Encoder) error {
+			if err := enc.StartMaster(testIDTest, UnknownSize); err != nil {
+				return err
+			}
+			if err := enc.StartMaster(testIDCluster, UnknownSize); err != nil {
+				return err
+			}
+			if err := enc.EndMaster(); err != nil {
+				return err
+			}
+			return enc.WriteUinteger(testIDTimestamp, 1)
+		}},
+		{name: "global after end", want: ErrInvalidTermination, write: func(enc *Encoder) error {
+			if err := enc.StartMaster(testIDTest, UnknownSize); err != nil {
+				return err
+			}
+			if err := enc.StartMaster(testIDCluster, UnknownSize); err != nil {
+				return err
+			}
+			if err := enc.EndMaster(); err != nil {
+				return err
+			}
+			return enc.WriteBinary(IDVoid, nil)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := NewEncoder(io.Discard)
+			if err := enc.EncodeHeader(&EBML{DocType: "test"}); err != nil {
+				t.Fatal(err)
+			}
+			err := tt.write(enc)
+			if err == nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// unseekable is an io.WriteSeeker which fails to seek, like a pipe.
+type unseekable struct {
+	bytes.Buffer
+}
+
+func (*unseekable) Seek(int64, int) (int64, error) {
+	return 0, errors.New("illegal seek")
+}
+
+func TestEncoder_patchSize(t *testing.T) {
+	doc := testDocument{
+		Info: testInfo{Title: "title", TimestampScale: 1000, DateUTC: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		Cluster: []testCluster{
+			{Timestamp: 1, Payload: [][]byte{bytes.Repeat([]byte{1}, 200)}},
+		},
+	}
+	encode := func(t *testing.T, w io.Writer) {
+		enc := NewEncoder(w)
+		if err := enc.EncodeHeader(&EBML{DocType: "test", EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8}); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.StartMaster(testIDTest, AutoSize); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Encode(testIDInfo, doc.Info); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Encode(testIDCluster, doc.Cluster); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.EndMaster(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.CreateTemp(t.TempDir(), "patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("prefix"); err != nil {
+		t.Fatal(err)
+	}
+	encode(t, f)
+	patched, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched = patched[len("prefix"):]
+
+	var buffered unseekable
+	encode(t, &buffered)
+
+	for name, b := range map[string][]byte{"patched": patched, "buffered": buffered.Bytes()} {
+		d := NewDecoder(bytes.NewReader(b))
+		if _, err := d.DecodeHeader(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var got testDocument
+		if err := d.DecodeBody(&got); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, doc) {
+			t.Errorf("%s: DecodeBody() = %+v, want %+v", name, got, doc)
+		}
+	}
+
+	// The header of the root element has an 8 octets data size when patched.
+	i := bytes.Index(patched, []byte{0x18, 0x53, 0x80, 0x67})
+	if i < 0 || patched[i+4] != 0x01 {
+		t.Errorf("patched root header = %x, want 8 octets data size", patched[i:i+12])
+	}
+	if len(buffered.Bytes()) >= len(patched) {
+		t.Errorf("len(buffered) = %d, want less than len(patched) = %d", len(buffered.Bytes()), len(patched))
+	}
+}
+
+func BenchmarkDecoder_DecodeHeader(b *testing.B) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).EncodeHeader(&testEBML); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	b.Run("generated", func(b *testing.B) {
+		for range b.N {
+			d := NewDecoder(bytes.NewReader(data))
+			el, _, _ := d.NextOf(RootEl, 0)
+			var h EBML
+			if err := d.Decode(el, &h); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reflect", func(b *testing.B) {
+		for range b.N {
+			d := NewDecoder(bytes.NewReader(data))
+			el, _, _ := d.NextOf(RootEl, 0)
+			var h reflectEBML
+			if err := d.Decode(el, &h); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestEncoder_EncodeBody_globalElement(t *testing.T) {
+	type info struct {
+		Title string
+		Void  []byte
+	}
+	type document struct {
+		Info info
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.EncodeHeader(&EBML{DocType: "test", EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.EncodeBody(document{Info: info{Title: "a", Void: []byte{0, 0}}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0xec, 0x82, 0, 0}; !bytes.HasSuffix(buf.Bytes(), want) {
+		t.Errorf("EncodeBody() = %x, want suffix %x", buf.Bytes(), want)
+	}
+}
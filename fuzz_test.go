@@ -0,0 +1,169 @@
+package ebml
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fuzzSeeds returns valid and damaged documents of the test DocType.
+func fuzzSeeds() [][]byte {
+	title := testElement(testIDTitle, []byte("title"))
+	info := testElement(testIDInfo,
+		title,
+		testElement(testIDTimestampScale, []byte{0x0f, 0x42, 0x40}),
+		testElement(testIDDuration, []byte{0x41, 0x48, 0, 0}),
+		testElement(testIDDateUTC, make([]byte, 8)),
+		testElement(testIDOffset, []byte{0xff}),
+	)
+	cluster := testElement(testIDCluster, testElement(testIDTimestamp, []byte{1}), testElement(testIDPayload, []byte{1, 2, 3}))
+	valid := append(testHeader("test"), testElement(testIDTest, info, cluster)...)
+	unknownSize := append(testHeader("test"), testUnknownSizeElement(testIDTest, info, testUnknownSizeElement(testIDCluster, testElement(testIDTimestamp, []byte{2})))...)
+	damaged := bytes.Clone(valid)
+	damaged[bytes.Index(damaged, title)] = 0
+	overflow := append(testHeader("test"), testElement(testIDTest, testElement(testIDInfo, title[:len(title)-1]))...)
+	huge := append(testHeader("test"), 0x18, 0x53, 0x80, 0x67, 0x01, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe)
+	return [][]byte{nil, valid, unknownSize, damaged, overflow, huge, valid[:len(valid)/2], testHeader("unknown")}
+}
+
+func FuzzDecoder_DecodeHeader(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		h, err := NewDecoder(bytes.NewReader(b)).DecodeHeader()
+		if err == nil && h == nil {
+			t.Fatal("DecodeHeader() = nil, nil")
+		}
+	})
+}
+
+func FuzzDecoder_DecodeBody(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		d := NewDecoder(bytes.NewReader(b))
+		if _, err := d.DecodeHeader(); err != nil {
+			return
+		}
+		var doc testDocument
+		_ = d.DecodeBody(&doc)
+
+		d = NewDecoder(bytes.NewReader(b))
+		d.SetBinaryMode(BinaryAlias)
+		if _, err := d.DecodeHeader(); err != nil {
+			return
+		}
+		var raw struct {
+			Info struct {
+				Title   []byte
+				DateUTC [8]byte
+				Unknown []RawElement `ebml:",unknown"`
+			}
+			Cluster []struct {
+				Timestamp int8
+				Payload   []Buffer
+			}
+		}
+		_ = d.DecodeBody(&raw)
+	})
+}
+
+func FuzzDecoder_DecodeDocument(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		nodes, err := NewDecoder(bytes.NewReader(b)).DecodeDocument()
+		if err != nil {
+			return
+		}
+		// A document which decodes without error must survive a round trip.
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).EncodeDocument(nodes); err != nil {
+			var undefined *UndefinedElementError
+			if errors.As(err, &undefined) {
+				return
+			}
+			t.Fatalf("EncodeDocument() error = %v", err)
+		}
+		got, err := NewDecoder(&buf).DecodeDocument()
+		if err != nil {
+			t.Fatalf("DecodeDocument() of the encoded document error = %v", err)
+		}
+		if !nodesEqual(got, nodes) {
+			t.Errorf("round trip = %v, want %v", got, nodes)
+		}
+	})
+}
+
+// nodesEqual compares Nodes like reflect.DeepEqual, but compares times
+// with time.Time.Equal and NaN floats as equal.
+func nodesEqual(a, b []*Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		if x.ID != y.ID || !nodesEqual(x.Children, y.Children) {
+			return false
+		}
+		switch v := x.Value.(type) {
+		case time.Time:
+			w, ok := y.Value.(time.Time)
+			if !ok || !v.Equal(w) {
+				return false
+			}
+		case float64:
+			w, ok := y.Value.(float64)
+			if !ok || v != w && (v == v || w == w) {
+				return false
+			}
+		default:
+			if !reflect.DeepEqual(x.Value, y.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func FuzzEncoder_roundTrip(f *testing.F) {
+	f.Add("title", uint64(1000000), 12.5, int64(0), int64(-1), uint64(1), []byte{1, 2, 3})
+	f.Add("", uint64(0), 0.0, int64(-1<<63), int64(1<<62), uint64(1<<64-1), []byte(nil))
+	f.Fuzz(func(t *testing.T, title string, scale uint64, duration float64, date int64, offset int64, timestamp uint64, payload []byte) {
+		in := testDocument{
+			Info: testInfo{
+				Title:          title,
+				TimestampScale: uint(scale),
+				Duration:       duration,
+				DateUTC:        time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(date)),
+				Offset:         int(offset),
+			},
+			// Empty binary data is decoded as an empty, non-nil slice.
+			Cluster: []testCluster{{Timestamp: uint(timestamp), Payload: [][]byte{append([]byte{}, payload...)}}},
+		}
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		if err := enc.EncodeHeader(&EBML{EBMLVersion: 1, EBMLReadVersion: 1, EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8, DocType: "test", DocTypeVersion: 1, DocTypeReadVersion: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.EncodeBody(in); err != nil {
+			t.Fatal(err)
+		}
+		d := NewDecoder(&buf)
+		if _, err := d.DecodeHeader(); err != nil {
+			t.Fatal(err)
+		}
+		var out testDocument
+		if err := d.DecodeBody(&out); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, in) && !(duration != duration && out.Info.Duration != out.Info.Duration) {
+			t.Errorf("round trip = %+v, want %+v", out, in)
+		}
+	})
+}
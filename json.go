@@ -0,0 +1,306 @@
+package ebml
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/coding-socks/ebml/schema"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EncodeJSON writes nodes, such as the ones returned by DecodeDocument,
+// as an indented JSON object.
+//
+// The children of a master element are an object whose keys are the names
+// of the elements. An element which the schema allows more than once is
+// an array, even when it occurs once; the elements of an array are written
+// where the first of them occurs. The top-level elements are arrays only
+// when they occur more than once. Binary values are base64 strings, dates
+// are RFC 3339 strings, and floats which are not finite are the strings
+// "NaN", "+Inf" and "-Inf". An element with UnknownSchema has its ID as the
+// key, such as "0x4001", and its data as a base64 string.
+func EncodeJSON(w io.Writer, nodes []*Node) error {
+	b, err := appendJSONObject(nil, nodes, true)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// jsonKey returns the key of n in the object of its parent.
+func jsonKey(n *Node) string {
+	if n.Schema.Name == UnknownSchema.Name {
+		return n.ID.String()
+	}
+	return n.Schema.Name
+}
+
+// appendJSONObject appends the object of nodes to b. The top-level
+// elements are arrays only when they occur more than once, as the
+// document has a single EBML Root Element.
+func appendJSONObject(b []byte, nodes []*Node, top bool) ([]byte, error) {
+	var keys []string
+	groups := make(map[string][]*Node)
+	for _, n := range nodes {
+		k := jsonKey(n)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], n)
+	}
+	b = append(b, '{')
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		b = append(append(b, key...), ':')
+		g := groups[k]
+		if len(g) == 1 && (top || !repeatable(g[0].Schema)) {
+			if b, err = appendJSONValue(b, g[0]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		b = append(b, '[')
+		for j, n := range g {
+			if j > 0 {
+				b = append(b, ',')
+			}
+			if b, err = appendJSONValue(b, n); err != nil {
+				return nil, err
+			}
+		}
+		b = append(b, ']')
+	}
+	return append(b, '}'), nil
+}
+
+// repeatable reports whether the schema allows sch more than once in
+// its parent.
+func repeatable(sch schema.Element) bool {
+	return sch.MaxOccurs.Unbounded() || sch.MaxOccurs.Val() > 1
+}
+
+func appendJSONValue(b []byte, n *Node) ([]byte, error) {
+	if n.Schema.Type == TypeMaster {
+		return appendJSONObject(b, n.Children, false)
+	}
+	var v any
+	switch val := n.Value.(type) {
+	case int64:
+		return strconv.AppendInt(b, val, 10), nil
+	case uint64:
+		return strconv.AppendUint(b, val, 10), nil
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			v = strconv.FormatFloat(val, 'g', -1, 64)
+			break
+		}
+		return strconv.AppendFloat(b, val, 'g', -1, 64), nil
+	case time.Time:
+		v = val.Format(time.RFC3339Nano)
+	case string, []byte:
+		v = val
+	default:
+		return nil, &EncodeElementError{ID: n.ID, SchemaPath: n.Schema.Path, Err: fmt.Errorf("ebml: unsupported node value of type %T", n.Value)}
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, j...), nil
+}
+
+// A JSONError describes a JSON value which cannot be converted into
+// an element.
+type JSONError struct {
+	Offset     int64  // input offset after the value
+	SchemaPath string // path of the element in the schema, if known
+	Err        error
+}
+
+func (e *JSONError) Error() string {
+	if e.SchemaPath == "" {
+		return fmt.Sprintf("ebml: JSON at offset %d: %v", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("ebml: JSON for %s at offset %d: %v", e.SchemaPath, e.Offset, e.Err)
+}
+
+func (e *JSONError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeJSON reads a JSON object in the form written by EncodeJSON and
+// returns its Nodes, which can be written with Encoder.EncodeDocument.
+//
+// The EBML object is read with the schema of the EBML Header, and the
+// other keys with the schema of its DocType. Any element can be given as
+// a single value or as an array.
+func DecodeJSON(r io.Reader) ([]*Node, error) {
+	jd := &jsonDecoder{dec: json.NewDecoder(r), def: HeaderDef}
+	jd.dec.UseNumber()
+	t, err := jd.token()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := jd.object(schema.Element{Type: TypeMaster}, t)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := jd.dec.Token(); err != io.EOF {
+		return nil, jd.error("", errors.New("unexpected data after the top-level object"))
+	}
+	return nodes, nil
+}
+
+type jsonDecoder struct {
+	dec *json.Decoder
+	def *Def
+}
+
+func (jd *jsonDecoder) error(path string, err error) *JSONError {
+	return &JSONError{Offset: jd.dec.InputOffset(), SchemaPath: path, Err: err}
+}
+
+// token reads the next token. It reports io.EOF as io.ErrUnexpectedEOF.
+func (jd *jsonDecoder) token() (json.Token, error) {
+	t, err := jd.dec.Token()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return t, err
+}
+
+// object reads the children of the master element parent, whose object
+// starts with t. The schema of the DocType is used after the EBML Header.
+func (jd *jsonDecoder) object(parent schema.Element, t json.Token) ([]*Node, error) {
+	if t != json.Delim('{') {
+		return nil, jd.error(parent.Path, fmt.Errorf("expected an object, found %v", t))
+	}
+	var nodes []*Node
+	for jd.dec.More() {
+		t, err := jd.token()
+		if err != nil {
+			return nil, err
+		}
+		sch, err := jd.lookup(parent.Path, t.(string))
+		if err != nil {
+			return nil, err
+		}
+		values, err := jd.values(sch)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, values...)
+		if parent.Path == "" && sch.ID == IDEBML && len(values) > 0 {
+			docType, _, _ := headerParams(values[len(values)-1])
+			if jd.def, err = Definition(docType); err != nil {
+				return nil, err
+			}
+		}
+	}
+	_, err := jd.token() // '}'
+	return nodes, err
+}
+
+// lookup returns the schema of the child named key of the element with
+// the given path.
+func (jd *jsonDecoder) lookup(path, key string) (schema.Element, error) {
+	def := jd.def
+	if path == "" && key == "EBML" {
+		def = HeaderDef
+	}
+	if sch, ok := def.childByName(path, key); ok {
+		return sch, nil
+	}
+	if strings.HasPrefix(key, "0x") {
+		if id, err := strconv.ParseUint(key[2:], 16, 64); err == nil {
+			sch := UnknownSchema
+			sch.ID = schema.ElementID(id)
+			return sch, nil
+		}
+	}
+	return schema.Element{}, jd.error(path, fmt.Errorf("unknown element %q", key))
+}
+
+// values reads one value, or an array of values, of the element sch.
+func (jd *jsonDecoder) values(sch schema.Element) ([]*Node, error) {
+	t, err := jd.token()
+	if err != nil {
+		return nil, err
+	}
+	if t != json.Delim('[') {
+		n, err := jd.value(sch, t)
+		if err != nil {
+			return nil, err
+		}
+		return []*Node{n}, nil
+	}
+	var nodes []*Node
+	for jd.dec.More() {
+		t, err := jd.token()
+		if err != nil {
+			return nil, err
+		}
+		n, err := jd.value(sch, t)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if _, err := jd.token(); err != nil { // ']'
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// value reads the value of the element sch, which starts with t.
+func (jd *jsonDecoder) value(sch schema.Element, t json.Token) (*Node, error) {
+	n := &Node{ID: sch.ID, Schema: sch}
+	if sch.Type == TypeMaster {
+		children, err := jd.object(sch, t)
+		n.Children = children
+		return n, err
+	}
+	num, isNum := t.(json.Number)
+	str, isStr := t.(string)
+	var err error
+	switch {
+	case sch.Type == TypeInteger && isNum:
+		n.Value, err = strconv.ParseInt(string(num), 10, 64)
+	case sch.Type == TypeUinteger && isNum:
+		n.Value, err = strconv.ParseUint(string(num), 10, 64)
+	case sch.Type == TypeFloat && isNum:
+		n.Value, err = strconv.ParseFloat(string(num), 64)
+	case sch.Type == TypeFloat && isStr:
+		n.Value, err = strconv.ParseFloat(str, 64)
+	case (sch.Type == TypeString || sch.Type == TypeUTF8) && isStr:
+		n.Value = str
+	case sch.Type == TypeDate && isStr:
+		n.Value, err = time.Parse(time.RFC3339Nano, str)
+	case (sch.Type == TypeBinary || sch.Type == UnknownSchema.Type) && isStr:
+		n.Value, err = base64.StdEncoding.DecodeString(str)
+	default:
+		err = fmt.Errorf("cannot use %v as %s value", t, sch.Type)
+	}
+	if err != nil {
+		return nil, jd.error(sch.Path, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,138 @@
+package ebml
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testJSON = `{
+  "EBML": {
+    "EBMLVersion": 1,
+    "EBMLReadVersion": 1,
+    "EBMLMaxIDLength": 4,
+    "EBMLMaxSizeLength": 8,
+    "DocType": "test",
+    "DocTypeVersion": 0,
+    "DocTypeReadVersion": 0
+  },
+  "Test": {
+    "Info": {
+      "Title": "title",
+      "TimestampScale": 1000000,
+      "Duration": 12.5,
+      "DateUTC": "2020-01-02T03:04:05Z",
+      "Offset": -1
+    },
+    "Cluster": [
+      {
+        "Timestamp": 1,
+        "Payload": [
+          "AQI=",
+          "Aw=="
+        ]
+      },
+      {
+        "Timestamp": 2
+      }
+    ]
+  }
+}
+`
+
+func TestEncodeJSON(t *testing.T) {
+	doc := testDocument{
+		Info: testInfo{
+			Title:          "title",
+			TimestampScale: 1000000,
+			Duration:       12.5,
+			DateUTC:        time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Offset:         -1,
+		},
+		Cluster: []testCluster{
+			{Timestamp: 1, Payload: [][]byte{{1, 2}, {3}}},
+			{Timestamp: 2},
+		},
+	}
+	nodes, err := NewDecoder(bytes.NewReader(encodeTestDocument(t, false, doc))).DecodeDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := EncodeJSON(&buf, nodes); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != testJSON {
+		t.Errorf("EncodeJSON() =\n%s\nwant\n%s", got, testJSON)
+	}
+
+	nodes, err = DecodeJSON(strings.NewReader(testJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := NewEncoder(&out).EncodeDocument(nodes); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDecoder(&out)
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var got testDocument
+	if err := d.DecodeBody(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("document = %+v, want %+v", got, doc)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	nodes, err := DecodeJSON(strings.NewReader(`{
+		"EBML": {"DocType": "test", "CRC-32": "AAAAAA=="},
+		"Test": {"Cluster": {"Timestamp": 1, "0x4001": "AQ=="}, "Info": [{"Duration": "+Inf"}]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("DecodeJSON() = %d nodes, want 2", len(nodes))
+	}
+	if n := nodes[0].Children[1]; n.ID != IDCRC32 {
+		t.Errorf("CRC-32 node ID = %v", n.ID)
+	}
+	cluster, info := nodes[1].Children[0], nodes[1].Children[1]
+	if n := cluster.Children[1]; n.ID != 0x4001 || n.Schema.Name != UnknownSchema.Name || !bytes.Equal(n.Value.([]byte), []byte{1}) {
+		t.Errorf("unknown node = %+v", n)
+	}
+	if n := info.Children[0]; n.ID != testIDDuration || n.Value != math.Inf(1) {
+		t.Errorf("Duration node = %+v", n)
+	}
+}
+
+func TestDecodeJSON_errors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"EBML": {"DocType": "test"}, "Test": {"Missing": 1}}`, `unknown element "Missing"`},
+		{`{"EBML": {"DocType": "test"}, "Test": {"Info": {"Title": 1}}}`, `\Test\Info\Title`},
+		{`{"EBML": {"DocType": "test"}, "Test": {"Cluster": {"Timestamp": -1}}}`, `invalid syntax`},
+		{`{"EBML": {"DocType": "test"}, "Test": 1}`, `expected an object`},
+		{`{"EBML": {"DocType": "test"}} {}`, `unexpected data`},
+	}
+	for _, tt := range tests {
+		_, err := DecodeJSON(strings.NewReader(tt.in))
+		var jsonErr *JSONError
+		if !errors.As(err, &jsonErr) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("DecodeJSON(%s) error = %v, want JSONError containing %q", tt.in, err, tt.want)
+		}
+	}
+	if _, err := DecodeJSON(strings.NewReader(`{"EBML": {"DocType": "unknown"}}`)); !errors.As(err, &UnknownDocTypeError{}) {
+		t.Errorf("DecodeJSON() error = %v, want UnknownDocTypeError", err)
+	}
+}
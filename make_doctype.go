@@ -15,6 +15,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -93,6 +94,9 @@ func gen(w io.Writer) {
 	root.VisitAll(func(node *schema.TreeNode) {
 		writeStruct(w, node)
 	})
+	root.VisitAll(func(node *schema.TreeNode) {
+		writeMethods(w, node)
+	})
 }
 
 func writeID(w io.Writer, node *schema.TreeNode) {
@@ -125,3 +129,132 @@ func writeStruct(w io.Writer, node *schema.TreeNode) {
 		writeStruct(w, n)
 	})
 }
+
+func idName(el schema.Element) string {
+	return "ID" + strings.ReplaceAll(el.Name, "-", "")
+}
+
+func repeated(el schema.Element) bool {
+	return el.MaxOccurs.Unbounded() || el.MaxOccurs.Val() > 1
+}
+
+// children returns the child nodes of node, including node itself
+// when it is recursive.
+func children(node *schema.TreeNode) []*schema.TreeNode {
+	var nodes []*schema.TreeNode
+	if node.El.Recursive {
+		nodes = append(nodes, node)
+	}
+	node.VisitAll(func(n *schema.TreeNode) {
+		nodes = append(nodes, n)
+	})
+	return nodes
+}
+
+func writeMethods(w io.Writer, node *schema.TreeNode) {
+	if node.El.Type != schema.TypeMaster {
+		return
+	}
+	writeDecodeMethod(w, node)
+	writeEncodeMethod(w, node)
+	node.VisitAll(func(n *schema.TreeNode) {
+		writeMethods(w, n)
+	})
+}
+
+var readMethods = map[string]struct{ method, conv string }{
+	schema.TypeInteger:  {"ReadInteger", "int(x)"},
+	schema.TypeUinteger: {"ReadUinteger", "uint(x)"},
+	schema.TypeFloat:    {"ReadFloat", "x"},
+	schema.TypeString:   {"ReadString", "x"},
+	schema.TypeUtf8:     {"ReadString", "x"},
+	schema.TypeDate:     {"ReadDate", "x"},
+	schema.TypeBinary:   {"ReadBinary", "x"},
+}
+
+func writeDecodeMethod(w io.Writer, node *schema.TreeNode) {
+	name := node.El.Name
+	fmt.Fprintf(w, "// DecodeEBML implements Unmarshaler.\n")
+	fmt.Fprintf(w, "func (v *%s) DecodeEBML(d *Decoder, el Element) error {", name)
+	node.VisitAll(func(n *schema.TreeNode) {
+		if n.El.Default == nil || repeated(n.El) {
+			return
+		}
+		switch n.El.Type {
+		case schema.TypeInteger, schema.TypeUinteger, schema.TypeFloat:
+			fmt.Fprintf(w, "\n\tv.%s = %s", n.El.Name, *n.El.Default)
+		case schema.TypeString, schema.TypeUtf8:
+			fmt.Fprintf(w, "\n\tv.%s = %s", n.El.Name, strconv.Quote(*n.El.Default))
+		}
+	})
+	fmt.Fprint(w, "\n\treturn d.DecodeChildren(el, func(el Element) error {")
+	fmt.Fprint(w, "\n\t\tswitch el.ID {")
+	for _, n := range children(node) {
+		field := n.El.Name
+		fmt.Fprintf(w, "\n\t\tcase %s:", idName(n.El))
+		if n.El.Type == schema.TypeMaster {
+			switch {
+			case n == node:
+				fmt.Fprintf(w, "\n\t\t\tv.%s = new(%s)", field, n.El.Name)
+				fmt.Fprintf(w, "\n\t\t\treturn d.DecodeMaster(el, v.%s)", field)
+			case repeated(n.El):
+				fmt.Fprintf(w, "\n\t\t\tvar x %s", n.El.Name)
+				fmt.Fprint(w, "\n\t\t\tif err := d.DecodeMaster(el, &x); err != nil {\n\t\t\t\treturn err\n\t\t\t}")
+				fmt.Fprintf(w, "\n\t\t\tv.%[1]s = append(v.%[1]s, x)", field)
+			default:
+				fmt.Fprintf(w, "\n\t\t\treturn d.DecodeMaster(el, &v.%s)", field)
+			}
+			continue
+		}
+		read := readMethods[n.El.Type]
+		fmt.Fprintf(w, "\n\t\t\tx, err := d.%s(el)", read.method)
+		fmt.Fprint(w, "\n\t\t\tif err != nil {\n\t\t\t\treturn err\n\t\t\t}")
+		if repeated(n.El) {
+			fmt.Fprintf(w, "\n\t\t\tv.%[1]s = append(v.%[1]s, %[2]s)", field, read.conv)
+		} else {
+			fmt.Fprintf(w, "\n\t\t\tv.%s = %s", field, read.conv)
+		}
+	}
+	fmt.Fprint(w, "\n\t\tdefault:\n\t\t\treturn d.Skip(el)")
+	fmt.Fprint(w, "\n\t\t}\n\t\treturn nil\n\t})\n}\n\n")
+}
+
+var writeMethodNames = map[string]struct{ method, conv string }{
+	schema.TypeInteger:  {"WriteInteger", "int64(%s)"},
+	schema.TypeUinteger: {"WriteUinteger", "uint64(%s)"},
+	schema.TypeFloat:    {"WriteFloat", "%s"},
+	schema.TypeString:   {"WriteString", "%s"},
+	schema.TypeUtf8:     {"WriteString", "%s"},
+	schema.TypeDate:     {"WriteDate", "%s"},
+	schema.TypeBinary:   {"WriteBinary", "%s"},
+}
+
+func writeEncodeMethod(w io.Writer, node *schema.TreeNode) {
+	fmt.Fprintf(w, "// EncodeEBML implements Marshaler.\n")
+	fmt.Fprintf(w, "func (v *%s) EncodeEBML(e *Encoder) error {", node.El.Name)
+	for _, n := range children(node) {
+		field := "v." + n.El.Name
+		id := idName(n.El)
+		switch {
+		case n == node:
+			fmt.Fprintf(w, "\n\tif %s != nil {", field)
+			fmt.Fprintf(w, "\n\t\tif err := e.WriteMaster(%s, %s); err != nil {\n\t\t\treturn err\n\t\t}", id, field)
+			fmt.Fprint(w, "\n\t}")
+		case n.El.Type == schema.TypeMaster && repeated(n.El):
+			fmt.Fprintf(w, "\n\tfor i := range %s {", field)
+			fmt.Fprintf(w, "\n\t\tif err := e.WriteMaster(%s, &%s[i]); err != nil {\n\t\t\treturn err\n\t\t}", id, field)
+			fmt.Fprint(w, "\n\t}")
+		case n.El.Type == schema.TypeMaster:
+			fmt.Fprintf(w, "\n\tif err := e.WriteMaster(%s, &%s); err != nil {\n\t\treturn err\n\t}", id, field)
+		case repeated(n.El):
+			write := writeMethodNames[n.El.Type]
+			fmt.Fprintf(w, "\n\tfor _, x := range %s {", field)
+			fmt.Fprintf(w, "\n\t\tif err := e.%s(%s, %s); err != nil {\n\t\t\treturn err\n\t\t}", write.method, id, fmt.Sprintf(write.conv, "x"))
+			fmt.Fprint(w, "\n\t}")
+		default:
+			write := writeMethodNames[n.El.Type]
+			fmt.Fprintf(w, "\n\tif err := e.%s(%s, %s); err != nil {\n\t\treturn err\n\t}", write.method, id, fmt.Sprintf(write.conv, field))
+		}
+	}
+	fmt.Fprint(w, "\n\treturn nil\n}\n\n")
+}
@@ -0,0 +1,181 @@
+package ebml
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// Source tells where the value of a field decoded by Decoder comes from.
+type Source int
+
+const (
+	// SourceAbsent means that the element of the field is absent and it
+	// has no default value. The field is left untouched.
+	SourceAbsent Source = iota
+	// SourceDefault means that the element of the field is absent, and
+	// the field holds the default value of the schema. A mandatory master
+	// element which is absent holds the default values of its children.
+	SourceDefault
+	// SourceDocument means that the element of the field is present in
+	// the document. An empty element still holds the default value of the
+	// schema, if it has one.
+	SourceDocument
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceAbsent:
+		return "absent"
+	case SourceDefault:
+		return "default"
+	case SourceDocument:
+		return "document"
+	}
+	return "Source(" + strconv.Itoa(int(s)) + ")"
+}
+
+// ElementInfo describes the element a field was decoded from.
+type ElementInfo struct {
+	Source Source
+	// Offset is the input offset of the element. It is the offset of the
+	// parent element when the element is absent.
+	Offset int64
+	// HeaderSize and DataSize are the sizes of the element, they are zero
+	// when the element is absent. DataSize is -1 for an unknown size.
+	HeaderSize int
+	DataSize   int64
+}
+
+// documentInfo returns the ElementInfo of el, which is present.
+func documentInfo(el Element) ElementInfo {
+	return ElementInfo{Source: SourceDocument, Offset: el.Offset, HeaderSize: el.HeaderSize, DataSize: el.DataSize}
+}
+
+// fieldRecorder is implemented by the types which record the fields of
+// the struct they are embedded into, see Presence and Meta.
+type fieldRecorder interface {
+	reset()
+	record(field string, info ElementInfo)
+}
+
+var (
+	typePresence = reflect.TypeOf(Presence{})
+	typeMeta     = reflect.TypeOf(Meta{})
+)
+
+// recorder returns the fieldRecorder of the struct val, if it has one.
+func (tinfo *typeInfo) recorder(val reflect.Value) fieldRecorder {
+	if tinfo.recorderIdx == nil {
+		return nil
+	}
+	fieldv, err := fieldByIndex(val, tinfo.recorderIdx)
+	if err != nil {
+		return nil
+	}
+	return fieldv.Addr().Interface().(fieldRecorder)
+}
+
+// Presence records where the values of the fields of a struct come from.
+// Embed it into a struct decoded by Decoder to tell an element which is
+// absent, and holds its default value, from an element which is present:
+//
+//	type Info struct {
+//		ebml.Presence
+//		TimestampScale uint
+//	}
+//
+//	if info.Source("TimestampScale") == ebml.SourceDefault { ... }
+//
+// Fields are identified by their Go name. Presence is ignored by Encoder.
+//
+// The values of types which implement Unmarshaler, such as the types
+// generated for the EBML Header, decode themselves. Their Presence is not
+// recorded, and an absent mandatory master element of such a type is left
+// untouched instead of holding the defaults of its children.
+type Presence struct {
+	sources map[string]Source
+}
+
+// Source returns where the value of the field named field comes from.
+func (p *Presence) Source(field string) Source {
+	return p.sources[field]
+}
+
+// Present reports whether the element of the field named field is
+// present in the document.
+func (p *Presence) Present(field string) bool {
+	return p.sources[field] == SourceDocument
+}
+
+func (p *Presence) reset() {
+	p.sources = nil
+}
+
+func (p *Presence) record(field string, info ElementInfo) {
+	if p.sources == nil {
+		p.sources = make(map[string]Source)
+	}
+	p.sources[field] = info.Source
+}
+
+// Meta records the elements the fields of a struct were decoded from.
+// It is like Presence, and it also records the position and the sizes of
+// each element, which is useful to edit a document in place or to report
+// a problem with a value:
+//
+//	type Info struct {
+//		ebml.Meta
+//		Title string
+//	}
+//
+//	if info, ok := info.Info("Title"); ok {
+//		fmt.Printf("Title at offset %d\n", info.Offset)
+//	}
+//
+// Fields are identified by their Go name. Meta is ignored by Encoder, and
+// it is not recorded for an Unmarshaler, like Presence. A
+// field of the struct hides the method with the same name, use the Meta
+// field then, as in info.Meta.Info("Title").
+type Meta struct {
+	infos map[string][]ElementInfo
+}
+
+// Source returns where the value of the field named field comes from.
+func (m *Meta) Source(field string) Source {
+	if infos := m.infos[field]; len(infos) != 0 {
+		return infos[0].Source
+	}
+	return SourceAbsent
+}
+
+// Present reports whether the element of the field named field is
+// present in the document.
+func (m *Meta) Present(field string) bool {
+	return m.Source(field) == SourceDocument
+}
+
+// Info returns the first element the field named field was decoded from.
+// It reports false when the element is absent and has no default value.
+func (m *Meta) Info(field string) (ElementInfo, bool) {
+	if infos := m.infos[field]; len(infos) != 0 {
+		return infos[0], true
+	}
+	return ElementInfo{}, false
+}
+
+// All returns the elements the field named field was decoded from, in
+// the order of the document. A slice field has one for each value.
+func (m *Meta) All(field string) []ElementInfo {
+	return m.infos[field]
+}
+
+func (m *Meta) reset() {
+	m.infos = nil
+}
+
+func (m *Meta) record(field string, info ElementInfo) {
+	if m.infos == nil {
+		m.infos = make(map[string][]ElementInfo)
+	}
+	m.infos[field] = append(m.infos[field], info)
+}
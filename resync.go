@@ -0,0 +1,171 @@
+package ebml
+
+import (
+	"errors"
+	"fmt"
+	"github.com/coding-socks/ebml/ebmltext"
+	"github.com/coding-socks/ebml/schema"
+	"io"
+	"slices"
+	"strings"
+)
+
+// A DamagedRange describes a part of the input which could not be
+// parsed and was skipped to find the next valid element.
+//
+// Start is inclusive and End is exclusive.
+type DamagedRange struct {
+	Start int64
+	End   int64
+}
+
+func (r DamagedRange) String() string {
+	return fmt.Sprintf("[%d, %d)", r.Start, r.End)
+}
+
+// A DamagedDataError describes a range skipped by the decoder while
+// recovering from damaged data.
+type DamagedDataError struct {
+	Range DamagedRange
+	Err   error // the error which triggered the recovery
+}
+
+func (e *DamagedDataError) Error() string {
+	return fmt.Sprintf("ebml: skipped damaged data %s: %v", e.Range, e.Err)
+}
+
+func (e *DamagedDataError) Unwrap() error {
+	return e.Err
+}
+
+// ResyncIDs returns the Element IDs a Decoder looks for when it tries
+// to recover from damaged data. These are the IDs of the EBML Root
+// Element, the EBML Header and all the Top-Level Elements.
+//
+// The Global Elements are excluded because they can appear anywhere.
+func (d *Def) ResyncIDs() []schema.ElementID {
+	ids := []schema.ElementID{IDEBML, d.Root.ID}
+	for el := range d.All() {
+		if el.ID == IDVoid || el.ID == IDCRC32 || el.ID == d.Root.ID {
+			continue
+		}
+		if strings.Count(el.Path, "\\") != 2 || strings.Contains(el.Path, "(") {
+			continue
+		}
+		if !strings.HasPrefix(el.Path, d.Root.Path+"\\") {
+			continue
+		}
+		ids = append(ids, el.ID)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// SetResyncIDs overrides the Element IDs used by Resync. Calling
+// SetResyncIDs without arguments restores the default which is
+// Def.ResyncIDs of the current definition.
+func (d *Decoder) SetResyncIDs(ids ...schema.ElementID) {
+	d.resyncIDs = slices.Clone(ids)
+}
+
+// Damaged returns the ranges of the input skipped by the Decoder
+// while recovering from damaged data.
+func (d *Decoder) Damaged() []DamagedRange {
+	return slices.Clone(d.damaged)
+}
+
+// Resync moves the reader forward until it finds the header
+// of an element which could be used to resume decoding. It returns the
+// skipped range of the input.
+//
+// A candidate is accepted when its ID is one of the resync IDs, its
+// data size is valid for its schema, and, for master elements, the
+// first child is an element defined within the candidate.
+//
+// When Resync reaches the end of the input it returns io.EOF together
+// with the skipped range.
+func (d *Decoder) Resync() (DamagedRange, error) {
+	d.el = nil
+	ids := d.resyncIDs
+	if len(ids) == 0 {
+		ids = d.def.ResyncIDs()
+	}
+	r := DamagedRange{Start: d.r.InputOffset()}
+	for {
+		b := d.r.Peek(resyncWindowSize)
+		if len(b) == 0 {
+			r.End = d.r.InputOffset()
+			return r, io.EOF
+		}
+		// A candidate needs resyncPeekSize octets unless the input ends
+		// within the window.
+		n := len(b)
+		if n == resyncWindowSize {
+			n -= resyncPeekSize - 1
+		}
+		i := 0
+		for i < n && !d.isResyncCandidate(b[i:min(i+resyncPeekSize, len(b))], ids) {
+			i++
+		}
+		if _, err := io.CopyN(io.Discard, d.r, int64(i)); err != nil {
+			r.End = d.r.InputOffset()
+			return r, err
+		}
+		if i < n {
+			r.End = d.r.InputOffset()
+			return r, nil
+		}
+	}
+}
+
+// resyncPeekSize is enough to hold an element header and the ID of
+// its first child.
+const resyncPeekSize = 3 * 8
+
+// resyncWindowSize is the number of octets scanned by Resync at once.
+const resyncWindowSize = 4096
+
+func (d *Decoder) isResyncCandidate(b []byte, ids []schema.ElementID) bool {
+	id, w, err := ebmltext.ParseElementID(b, d.r.MaxIDLength)
+	if err != nil || !slices.Contains(ids, id) {
+		return false
+	}
+	sch, ok := d.def.Get(id)
+	if !ok {
+		return false
+	}
+	ds, sw, err := ebmltext.ParseElementDataSize(b[w:], d.r.MaxSizeLength)
+	if err != nil {
+		return false
+	}
+	if ds == -1 {
+		return sch.UnknownSizeAllowed
+	}
+	if sch.Type != TypeMaster || ds == 0 || len(b) == w+sw {
+		return true
+	}
+	childID, _, err := ebmltext.ParseElementID(b[w+sw:], d.r.MaxIDLength)
+	if err != nil {
+		return false
+	}
+	child, ok := d.def.Get(childID)
+	if !ok {
+		return false
+	}
+	return isChild(Element{Schema: sch}, Element{ID: childID, Schema: child})
+}
+
+// recover skips damaged data after cause was encountered at offset start
+// and records the skipped range.
+func (d *Decoder) recover(start int64, cause error) error {
+	r, err := d.Resync()
+	r.Start = min(r.Start, start)
+	d.damaged = append(d.damaged, r)
+	d.skippedErrs = errors.Join(d.skippedErrs, &DamagedDataError{Range: r, Err: cause})
+	return err
+}
+
+// isDamage reports whether err signals an invalid element header.
+func isDamage(err error) bool {
+	return errors.Is(err, ErrInvalidVINTLength) || errors.Is(err, ebmltext.ErrAllOneVINT)
+}
@@ -0,0 +1,188 @@
+package ebml
+
+import (
+	"errors"
+	"github.com/coding-socks/ebml/ebmltext"
+	"io"
+)
+
+// An Action tells a Rewriter what to do with an element after the
+// transforms were called.
+type Action int
+
+const (
+	// Keep writes the element after the elements written by the
+	// transform, and passes the element to the following transforms.
+	Keep Action = iota
+	// Skip omits the element. The elements written by the transform
+	// replace it.
+	Skip
+)
+
+// A Transform is called by a Rewriter for each element, after its header
+// was read and before its data is read.
+//
+// The elements written to the Encoder by the transform are inserted
+// before el. To change the value of el, the transform reads it with one
+// of the Read methods of the Decoder, writes the new value and returns
+// Skip. To insert elements after el, the transform calls Rewriter.Copy
+// before writing them and returns Skip. The data of el must not be read
+// when the transform returns Keep.
+type Transform func(rw *Rewriter, el Element) (Action, error)
+
+// A Rewriter copies an EBML Document from a Decoder to an Encoder, and
+// passes each element through transforms on the way.
+//
+// The data of leaf elements which are kept is copied without decoding.
+// Master elements are written with a data size computed by the Encoder,
+// which converts master elements of unknown size to master elements of
+// known size. A CRC-32 element which starts a master element is not passed
+// through the transforms, it is computed again over the children written,
+// so that it stays valid when the transforms change them.
+type Rewriter struct {
+	d          *Decoder
+	e          *Encoder
+	transforms []Transform
+	// crc reports whether the next element is the CRC-32 element which
+	// was replaced by Copy.
+	crc bool
+}
+
+// NewRewriter returns a Rewriter which reads from d and writes to e. The
+// transforms are called in order until one of them returns Skip.
+func NewRewriter(d *Decoder, e *Encoder, transforms ...Transform) *Rewriter {
+	return &Rewriter{d: d, e: e, transforms: transforms}
+}
+
+// Decoder returns the Decoder of rw.
+func (rw *Rewriter) Decoder() *Decoder {
+	return rw.d
+}
+
+// Encoder returns the Encoder of rw.
+func (rw *Rewriter) Encoder() *Encoder {
+	return rw.e
+}
+
+// Rewrite copies the EBML Header and the EBML Body. The EBML Header is
+// written as it is decoded, and the elements of the EBML Body are passed
+// through the transforms.
+func (rw *Rewriter) Rewrite() error {
+	h, err := rw.d.DecodeHeader()
+	if err != nil {
+		return err
+	}
+	if err := rw.e.EncodeHeader(h); err != nil {
+		return err
+	}
+	rw.d.skippedErrs = nil
+	for {
+		el, _, err := rw.d.NextOf(RootEl, 0)
+		if isDamage(err) {
+			if err := rw.d.recover(rw.d.r.InputOffset(), err); err != nil {
+				return errors.Join(err, rw.d.skippedErrs)
+			}
+			continue
+		}
+		if err == io.EOF {
+			return rw.d.skippedErrs
+		}
+		if err != nil {
+			return err
+		}
+		if err := rw.rewrite(el); err != nil {
+			return err
+		}
+	}
+}
+
+// rewrite passes el through the transforms.
+func (rw *Rewriter) rewrite(el Element) error {
+	if rw.crc {
+		rw.crc = false
+		if el.ID == IDCRC32 {
+			return rw.d.Skip(el)
+		}
+	}
+	start := rw.d.r.InputOffset()
+	for _, t := range rw.transforms {
+		action, err := t(rw, el)
+		if err != nil {
+			return err
+		}
+		consumed := rw.d.r.InputOffset() != start
+		if action == Skip {
+			if consumed {
+				return nil
+			}
+			return rw.d.Skip(el)
+		}
+		if consumed {
+			return newElementError(el, errors.New("ebml: the data of a kept element was read by a transform"))
+		}
+	}
+	return rw.Copy(el)
+}
+
+// Copy writes el, whose data was not read yet, to the Encoder. The data
+// of a leaf element is copied without decoding it, and the children of
+// a master element are passed through the transforms.
+func (rw *Rewriter) Copy(el Element) error {
+	if el.Schema.Type == TypeMaster {
+		var err error
+		if rw.crc = rw.startsWithCRC(el); rw.crc {
+			err = rw.e.startMasterCRC(el.ID)
+		} else {
+			err = rw.e.StartMaster(el.ID, AutoSize)
+		}
+		if err != nil {
+			return err
+		}
+		if err := rw.d.DecodeChildren(el, rw.rewrite); err != nil {
+			return err
+		}
+		return rw.e.EndMaster()
+	}
+	if el.DataSize == -1 {
+		return newElementError(el, ErrUnknownSizeNotAllowed)
+	}
+	if rw.e.canonical && el.Schema.Type != TypeBinary {
+		// The value may need to be written in another form.
+		b, err := rw.d.readAlloc(el, rw.d.scratch)
+		if err != nil {
+			return err
+		}
+		return rw.e.writeElement(el.ID, b)
+	}
+	if err := rw.e.begin(el.ID); err != nil {
+		return err
+	}
+	if _, err := rw.e.w.WriteElementID(el.ID); err != nil {
+		return err
+	}
+	if _, err := rw.e.w.WriteElementDataSize(el.DataSize, 0); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(rw.e.w, rw.d.r, el.DataSize); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return newElementError(el, err)
+	}
+	return nil
+}
+
+// startsWithCRC reports whether the first child of the master element el,
+// whose data was not read yet, is a CRC-32 element.
+func (rw *Rewriter) startsWithCRC(el Element) bool {
+	if el.DataSize == 0 || rw.d.el != nil {
+		return false
+	}
+	b := rw.d.r.Peek(int(rw.d.r.MaxIDLength + rw.d.r.MaxSizeLength))
+	id, w, err := ebmltext.ParseElementID(b, rw.d.r.MaxIDLength)
+	if err != nil || id != IDCRC32 {
+		return false
+	}
+	ds, _, err := ebmltext.ParseElementDataSize(b[w:], rw.d.r.MaxSizeLength)
+	return err == nil && ds == 4
+}
@@ -0,0 +1,147 @@
+package ebml
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/ebml/ebmltext"
+)
+
+func TestRewriter(t *testing.T) {
+	var in bytes.Buffer
+	in.Write(testHeader("test"))
+	in.Write(testUnknownSizeElement(testIDTest,
+		testElement(testIDInfo,
+			testElement(testIDTitle, []byte("secret")),
+			testElement(testIDTimestampScale, []byte{0x03, 0xe8}),
+		),
+		testUnknownSizeElement(testIDCluster,
+			testElement(testIDTimestamp, []byte{1}),
+			testElement(testIDPayload, []byte{1, 2, 3}),
+		),
+		testElement(IDVoid, []byte{0, 0}),
+		testUnknownSizeElement(testIDCluster,
+			testElement(testIDTimestamp, []byte{2}),
+			testElement(testIDPayload, []byte{4, 5}),
+		),
+	))
+
+	var out bytes.Buffer
+	d := NewDecoder(bytes.NewReader(in.Bytes()))
+	e := NewEncoder(&out)
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	rw := NewRewriter(d, e,
+		func(rw *Rewriter, el Element) (Action, error) {
+			switch el.ID {
+			case IDVoid, testIDPayload:
+				return Skip, nil
+			case testIDTitle:
+				return Skip, e.WriteString(el.ID, "anonymous")
+			case testIDTimestamp:
+				// Modify the value and insert a sibling after it.
+				ts, err := d.ReadUinteger(el)
+				if err != nil {
+					return Skip, err
+				}
+				if err := e.WriteUinteger(el.ID, ts*10); err != nil {
+					return Skip, err
+				}
+				return Skip, e.WriteBinary(testIDPayload, []byte{byte(ts)})
+			case testIDTimestampScale:
+				// Insert a sibling before it.
+				return Keep, e.WriteDate(testIDDateUTC, date)
+			}
+			return Keep, nil
+		},
+	)
+	if err := rw.Rewrite(); err != nil {
+		t.Fatal(err)
+	}
+
+	d = NewDecoder(bytes.NewReader(out.Bytes()))
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var got testDocument
+	if err := d.DecodeBody(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := testDocument{
+		Info: testInfo{Title: "anonymous", TimestampScale: 1000, DateUTC: date, Offset: -1},
+		Cluster: []testCluster{
+			{Timestamp: 10, Payload: [][]byte{{1}}},
+			{Timestamp: 20, Payload: [][]byte{{2}}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("document = %+v, want %+v", got, want)
+	}
+
+	// Master elements of unknown size are written with a known size.
+	r := ebmltext.NewDecoder(bytes.NewReader(out.Bytes()[len(testHeader("test")):]))
+	if _, err := r.ReadElementID(); err != nil {
+		t.Fatal(err)
+	}
+	if ds, err := r.ReadElementDataSize(); err != nil || ds == -1 {
+		t.Errorf("data size of Test = %d, %v, want known size", ds, err)
+	}
+}
+
+func TestRewriter_copy(t *testing.T) {
+	doc := testDocument{
+		Info:    testInfo{Title: "title", TimestampScale: 1000000, Duration: 1.5, Offset: -1, DateUTC: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		Cluster: []testCluster{{Timestamp: 1, Payload: [][]byte{{1, 2}, {3}}}},
+	}
+	in := encodeTestDocument(t, false, doc)
+	var out bytes.Buffer
+	if err := NewRewriter(NewDecoder(bytes.NewReader(in)), NewEncoder(&out)).Rewrite(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), in) {
+		t.Errorf("Rewrite() = %x, want %x", out.Bytes(), in)
+	}
+}
+
+func TestRewriter_crc(t *testing.T) {
+	title := testElement(testIDTitle, []byte("secret"))
+	ts := testElement(testIDTimestamp, []byte{1})
+	payload := testElement(testIDPayload, []byte{1, 2, 3})
+	in := append(testHeader("test"), testUnknownSizeElement(testIDTest,
+		testElement(testIDInfo, testCRC(title), title),
+		testUnknownSizeElement(testIDCluster, testCRC(ts, payload), ts, payload),
+	)...)
+	if report, err := Validate(bytes.NewReader(in), ValidateOptions{}); err != nil || !report.Valid {
+		t.Fatalf("Validate(input) = %+v, %v", report, err)
+	}
+
+	var out bytes.Buffer
+	d := NewDecoder(bytes.NewReader(in))
+	e := NewEncoder(&out)
+	rw := NewRewriter(d, e, func(rw *Rewriter, el Element) (Action, error) {
+		switch el.ID {
+		case IDCRC32:
+			t.Error("CRC-32 passed through the transforms")
+		case testIDTitle:
+			return Skip, e.WriteString(el.ID, "anonymous")
+		case testIDPayload:
+			return Skip, nil
+		}
+		return Keep, nil
+	})
+	if err := rw.Rewrite(); err != nil {
+		t.Fatal(err)
+	}
+	report, err := Validate(bytes.NewReader(out.Bytes()), ValidateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || len(report.Issues) != 0 {
+		t.Errorf("Validate() = %+v, want a valid report without issues", report)
+	}
+	want := testElement(testIDInfo, testCRC(testElement(testIDTitle, []byte("anonymous"))), testElement(testIDTitle, []byte("anonymous")))
+	if !bytes.Contains(out.Bytes(), want) {
+		t.Errorf("Rewrite() = %x, want Info %x", out.Bytes(), want)
+	}
+}
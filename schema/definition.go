@@ -22,7 +22,7 @@ var (
 type Documentation struct {
 	Content string `xml:",chardata"`
 	Lang    string `xml:"lang,attr"`
-	Purpose string `xml:"purpose"`
+	Purpose string `xml:"purpose,attr"`
 }
 
 var (
@@ -83,6 +83,16 @@ func (h *ElementID) UnmarshalXMLAttr(attr xml.Attr) error {
 	return nil
 }
 
+// MarshalText encodes the ID in hexadecimal notation, like String.
+func (h ElementID) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText decodes an ID in the notation of the schema attributes.
+func (h *ElementID) UnmarshalText(text []byte) error {
+	return h.UnmarshalXMLAttr(xml.Attr{Value: string(text)})
+}
+
 type Element struct {
 	Documentation      []Documentation `xml:"documentation"`
 	ImplementationNote []Note          `xml:"implementation_note"`
@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"encoding/json"
 	"encoding/xml"
 	"testing"
 )
@@ -36,3 +37,37 @@ func TestElementID_UnmarshalXMLAttr(t *testing.T) {
 		})
 	}
 }
+
+func TestElementID_MarshalText(t *testing.T) {
+	b, err := json.Marshal(ElementID(0x1a45dfa3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `"0x1a45dfa3"`; got != want {
+		t.Errorf("Marshal() = %v, want %v", got, want)
+	}
+	var h ElementID
+	if err := json.Unmarshal(b, &h); err != nil {
+		t.Fatal(err)
+	}
+	if h != 0x1a45dfa3 {
+		t.Errorf("Unmarshal() = %v, want 0x1a45dfa3", h)
+	}
+}
+
+func TestElement_UnmarshalXML_documentation(t *testing.T) {
+	data := []byte(`<element name="A" path="\A" id="0x81" type="uinteger">
+	<documentation lang="en" purpose="definition">Text.</documentation>
+	<implementation_note note_attribute="default">Note.</implementation_note>
+</element>`)
+	var el Element
+	if err := xml.Unmarshal(data, &el); err != nil {
+		t.Fatal(err)
+	}
+	if len(el.Documentation) != 1 || el.Documentation[0] != (Documentation{Content: "Text.", Lang: "en", Purpose: PurposeDefinition}) {
+		t.Errorf("Documentation = %+v", el.Documentation)
+	}
+	if len(el.ImplementationNote) != 1 || el.ImplementationNote[0].NoteAttribute != NoteAttributeDefault {
+		t.Errorf("ImplementationNote = %+v", el.ImplementationNote)
+	}
+}
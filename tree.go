@@ -0,0 +1,207 @@
+package ebml
+
+import (
+	"errors"
+	"fmt"
+	"github.com/coding-socks/ebml/schema"
+	"io"
+	"time"
+)
+
+// A Node is an element read without a Go type. It holds the value of a
+// leaf element, or the children of a master element.
+type Node struct {
+	ID     schema.ElementID
+	Schema schema.Element
+	// Value is an int64, uint64, float64, string, time.Time or []byte
+	// depending on the type of the element. The value of an element with
+	// UnknownSchema is a []byte.
+	Value    any
+	Children []*Node
+}
+
+// DecodeNode reads el and its descendants into a Node. Void elements
+// are skipped.
+func (d *Decoder) DecodeNode(el Element) (*Node, error) {
+	n := &Node{ID: el.ID, Schema: el.Schema}
+	var err error
+	switch el.Schema.Type {
+	case TypeMaster:
+		err = d.DecodeChildren(el, func(el Element) error {
+			if el.ID == IDVoid {
+				return d.Skip(el)
+			}
+			child, err := d.DecodeNode(el)
+			if err != nil {
+				return err
+			}
+			n.Children = append(n.Children, child)
+			return nil
+		})
+	case TypeInteger:
+		n.Value, err = d.ReadInteger(el)
+	case TypeUinteger:
+		n.Value, err = d.ReadUinteger(el)
+	case TypeFloat:
+		n.Value, err = d.ReadFloat(el)
+	case TypeString, TypeUTF8:
+		n.Value, err = d.ReadString(el)
+	case TypeDate:
+		n.Value, err = d.ReadDate(el)
+	default:
+		var b []byte
+		if b, err = d.ReadBinary(el); err == nil {
+			n.Value = append([]byte(nil), b...)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// DecodeDocument reads the EBML Header and the EBML Body into Nodes. The
+// EBML Body is read with the schema of the DocType of the EBML Header.
+func (d *Decoder) DecodeDocument() ([]*Node, error) {
+	var nodes []*Node
+	d.skippedErrs = nil
+	for {
+		el, _, err := d.NextOf(RootEl, 0)
+		if isDamage(err) {
+			if err := d.recover(d.r.InputOffset(), err); err != nil {
+				return nodes, errors.Join(err, d.skippedErrs)
+			}
+			continue
+		}
+		if err == io.EOF {
+			return nodes, d.skippedErrs
+		}
+		if err != nil {
+			return nodes, err
+		}
+		switch el.ID {
+		case IDVoid:
+			if err := d.Skip(el); err != nil {
+				return nodes, err
+			}
+			continue
+		case IDEBML:
+			d.def = HeaderDef
+			d.r.MaxIDLength = DefaultMaxIDLength
+			d.r.MaxSizeLength = DefaultMaxSizeLength
+		}
+		n, err := d.DecodeNode(el)
+		if err != nil {
+			return nodes, err
+		}
+		nodes = append(nodes, n)
+		if el.ID == IDEBML {
+			docType, idLen, sizeLen := headerParams(n)
+			if d.def, err = Definition(docType); err != nil {
+				return nodes, err
+			}
+			d.r.MaxIDLength, d.r.MaxSizeLength = idLen, sizeLen
+		}
+	}
+}
+
+// headerParams returns the values of the EBML Header node h which are
+// needed to read the EBML Body.
+func headerParams(h *Node) (docType string, idLen, sizeLen uint) {
+	idLen, sizeLen = DefaultMaxIDLength, DefaultMaxSizeLength
+	for _, c := range h.Children {
+		switch v := c.Value.(type) {
+		case string:
+			if c.ID == IDDocType {
+				docType = v
+			}
+		case uint64:
+			switch c.ID {
+			case IDEBMLMaxIDLength:
+				idLen = uint(v)
+			case IDEBMLMaxSizeLength:
+				sizeLen = uint(v)
+			}
+		}
+	}
+	return docType, idLen, sizeLen
+}
+
+// EncodeNode writes n and its descendants. Master elements are written
+// with AutoSize.
+func (e *Encoder) EncodeNode(n *Node) error {
+	if n.Schema.Type == TypeMaster {
+		if err := e.StartMaster(n.ID, AutoSize); err != nil {
+			return err
+		}
+		for _, c := range n.Children {
+			if err := e.EncodeNode(c); err != nil {
+				return err
+			}
+		}
+		return e.EndMaster()
+	}
+	if !nodeValueFits(n.Schema.Type, n.Value) {
+		return &EncodeElementError{ID: n.ID, SchemaPath: n.Schema.Path, Err: fmt.Errorf("ebml: node value of type %T for %s element", n.Value, n.Schema.Type)}
+	}
+	switch v := n.Value.(type) {
+	case int64:
+		return e.WriteInteger(n.ID, v)
+	case uint64:
+		return e.WriteUinteger(n.ID, v)
+	case float64:
+		return e.WriteFloat(n.ID, v)
+	case string:
+		return e.WriteString(n.ID, v)
+	case time.Time:
+		return e.WriteDate(n.ID, v)
+	}
+	return e.WriteBinary(n.ID, n.Value.([]byte))
+}
+
+// nodeValueFits reports whether val is a Node value for an element of
+// type typ.
+func nodeValueFits(typ string, val any) bool {
+	switch val.(type) {
+	case int64:
+		return typ == TypeInteger
+	case uint64:
+		return typ == TypeUinteger
+	case float64:
+		return typ == TypeFloat
+	case string:
+		return typ == TypeString || typ == TypeUTF8
+	case time.Time:
+		return typ == TypeDate
+	case []byte:
+		return typ == TypeBinary || typ == UnknownSchema.Type
+	}
+	return false
+}
+
+// EncodeDocument writes the Nodes read by DecodeDocument. The EBML Body
+// is written with the schema of the DocType of the EBML Header node.
+func (e *Encoder) EncodeDocument(nodes []*Node) error {
+	for _, n := range nodes {
+		if n.ID != IDEBML {
+			if err := e.EncodeNode(n); err != nil {
+				return err
+			}
+			continue
+		}
+		docType, idLen, sizeLen := headerParams(n)
+		def, err := Definition(docType)
+		if err != nil {
+			return err
+		}
+		e.def = HeaderDef
+		e.w.MaxIDLength = DefaultMaxIDLength
+		e.w.MaxSizeLength = DefaultMaxSizeLength
+		if err := e.EncodeNode(n); err != nil {
+			return err
+		}
+		e.def = def
+		e.w.MaxIDLength, e.w.MaxSizeLength = idLen, sizeLen
+	}
+	return nil
+}
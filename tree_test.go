@@ -0,0 +1,58 @@
+package ebml
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestEncoder_EncodeNode(t *testing.T) {
+	enc := NewEncoder(io.Discard)
+	if err := enc.EncodeHeader(&EBML{DocType: "test", EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8}); err != nil {
+		t.Fatal(err)
+	}
+	sch, _ := enc.def.Get(testIDTimestamp)
+	err := enc.EncodeNode(&Node{ID: testIDTimestamp, Schema: sch, Value: int64(1)})
+	var encErr *EncodeElementError
+	if !errors.As(err, &encErr) {
+		t.Errorf("EncodeNode() error = %v, want EncodeElementError", err)
+	}
+}
+
+func TestDecoder_DecodeDocument(t *testing.T) {
+	b := append(testHeader("test"), testUnknownSizeElement(testIDTest,
+		testElement(IDVoid, []byte{0}),
+		testElement(testIDCluster,
+			testElement(testIDTimestamp, []byte{1}),
+			testElement(0x4001, []byte{2}),
+		),
+	)...)
+	nodes, err := NewDecoder(bytes.NewReader(b)).DecodeDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 || len(nodes[1].Children) != 1 || len(nodes[1].Children[0].Children) != 2 {
+		t.Fatalf("DecodeDocument() = %+v", nodes)
+	}
+	cluster := nodes[1].Children[0]
+	unknown := cluster.Children[1]
+	if ts := cluster.Children[0]; ts.Value != uint64(1) {
+		t.Errorf("Timestamp = %v, want 1", ts.Value)
+	}
+	if unknown.Schema.Name != UnknownSchema.Name || !bytes.Equal(unknown.Value.([]byte), []byte{2}) {
+		t.Errorf("unknown node = %+v", unknown)
+	}
+
+	var out bytes.Buffer
+	if err := NewEncoder(&out).EncodeDocument(nodes); err != nil {
+		t.Fatal(err)
+	}
+	again, err := NewDecoder(&out).DecodeDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 2 || len(again[1].Children[0].Children) != 2 {
+		t.Errorf("DecodeDocument(EncodeDocument()) = %+v", again)
+	}
+}
@@ -1,25 +1,56 @@
 package ebml
 
 import (
+	"fmt"
+	"github.com/coding-socks/ebml/schema"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 // typeInfo holds details for the ebml representation of a type.
 type typeInfo struct {
-	ebmlID *fieldInfo
 	fields []fieldInfo
+	// unknown is the field collecting the elements without a field.
+	unknown *fieldInfo
+	// recorderIdx is the index of the Presence or Meta field, if any.
+	recorderIdx []int
 }
 
 // fieldInfo holds details for the ebml representation of a single field.
 type fieldInfo struct {
-	idx     []int
-	name    string
-	parents []string
+	idx    []int
+	name   string
+	id     schema.ElementID // set when the tag selects the element by ID
+	typ    string           // type hint for elements missing from the schema
+	goName string
+	flags  fieldFlags
+	pos    int // position in the fields of the typeInfo
 }
 
+type fieldFlags int
+
+const (
+	fOmitEmpty fieldFlags = 1 << iota
+	fInline
+	fUnknown
+)
+
+var tinfoMap sync.Map // map[reflect.Type]*typeInfo
+
+var typeRawElements = reflect.TypeOf([]RawElement(nil))
+
 // getTypeInfo returns the typeInfo structure with details necessary
 // for marshaling and unmarshaling typ.
+//
+// The result is cached for the lifetime of the program, and it is safe
+// to call getTypeInfo concurrently.
 func getTypeInfo(typ reflect.Type) (*typeInfo, error) {
+	if ti, ok := tinfoMap.Load(typ); ok {
+		return ti.(*typeInfo), nil
+	}
+
 	tinfo := &typeInfo{}
 	if typ.Kind() == reflect.Struct {
 		n := typ.NumField()
@@ -28,8 +59,22 @@ func getTypeInfo(typ reflect.Type) (*typeInfo, error) {
 			if (f.PkgPath != "" && !f.Anonymous) || f.Tag.Get("ebml") == "-" {
 				continue // Private field
 			}
+			if f.Type == typePresence || f.Type == typeMeta {
+				if tinfo.recorderIdx != nil {
+					return nil, fmt.Errorf("ebml: %s has multiple Presence or Meta fields", typ)
+				}
+				tinfo.recorderIdx = f.Index
+				continue
+			}
+
+			finfo, err := structFieldInfo(typ, &f)
+			if err != nil {
+				return nil, err
+			}
 
-			if f.Anonymous {
+			// An anonymous struct field without a name in its tag is
+			// embedded, like a field with the inline option.
+			if (f.Anonymous && finfo.name == f.Name && finfo.id == 0) || finfo.flags&fInline != 0 {
 				t := f.Type
 				if t.Kind() == reflect.Ptr {
 					t = t.Elem()
@@ -39,36 +84,89 @@ func getTypeInfo(typ reflect.Type) (*typeInfo, error) {
 					if err != nil {
 						return nil, err
 					}
-					if tinfo.ebmlID == nil {
-						tinfo.ebmlID = inner.ebmlID
-					}
 					for _, finfo := range inner.fields {
 						finfo.idx = append([]int{i}, finfo.idx...)
 						tinfo.fields = append(tinfo.fields, finfo)
 					}
+					if inner.recorderIdx != nil && tinfo.recorderIdx == nil {
+						tinfo.recorderIdx = append([]int{i}, inner.recorderIdx...)
+					}
 					continue
 				}
+				if finfo.flags&fInline != 0 {
+					return nil, fmt.Errorf("ebml: inline field %s.%s must be a struct", typ, f.Name)
+				}
 			}
-
-			finfo, err := structFieldInfo(typ, &f)
-			if err != nil {
-				return nil, err
+			if f.PkgPath != "" {
+				continue // Private embedded non-struct
 			}
 
 			// Add the field if it doesn't conflict with other fields.
 			tinfo.fields = append(tinfo.fields, *finfo)
 		}
 	}
-	return tinfo, nil
+	for i := range tinfo.fields {
+		finfo := &tinfo.fields[i]
+		finfo.pos = i
+		if finfo.flags&fUnknown == 0 {
+			continue
+		}
+		if tinfo.unknown != nil {
+			return nil, fmt.Errorf("ebml: %s has multiple unknown fields", typ)
+		}
+		tinfo.unknown = finfo
+	}
+	ti, _ := tinfoMap.LoadOrStore(typ, tinfo)
+	return ti.(*typeInfo), nil
 }
 
 // structFieldInfo builds and returns a fieldInfo for f.
 func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, error) {
-	finfo := &fieldInfo{idx: f.Index}
+	finfo := &fieldInfo{idx: f.Index, goName: f.Name}
 
 	tag := f.Tag.Get("ebml")
+	name, opts, _ := strings.Cut(tag, ",")
+	if strings.Contains(name, "=") {
+		name, opts = "", tag
+	}
+	for _, opt := range strings.Split(opts, ",") {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "":
+		case "omitempty":
+			finfo.flags |= fOmitEmpty
+		case "inline":
+			finfo.flags |= fInline
+		case "unknown":
+			finfo.flags |= fUnknown
+		case "id":
+			name = value
+		case "type":
+			switch value {
+			case TypeInteger, TypeUinteger, TypeFloat, TypeString, TypeDate, TypeUTF8, TypeMaster, TypeBinary:
+			default:
+				return nil, fmt.Errorf("ebml: unknown type %q in tag of %s.%s", value, typ, f.Name)
+			}
+			finfo.typ = value
+		default:
+			return nil, fmt.Errorf("ebml: unknown option %q in tag of %s.%s", opt, typ, f.Name)
+		}
+	}
+	if finfo.flags&fUnknown != 0 && f.Type != typeRawElements {
+		return nil, fmt.Errorf("ebml: unknown field %s.%s must be of type %s", typ, f.Name, typeRawElements)
+	}
 
-	name := tag
+	if strings.HasPrefix(name, "0x") {
+		id, err := strconv.ParseUint(name, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ebml: invalid element ID %q in tag of %s.%s", name, typ, f.Name)
+		}
+		finfo.id = schema.ElementID(id)
+		name = ""
+	}
+	if finfo.typ != "" && finfo.id == 0 {
+		return nil, fmt.Errorf("ebml: type in tag of %s.%s requires an element ID", typ, f.Name)
+	}
 	if name == "" {
 		name = f.Name
 	}
@@ -77,6 +175,82 @@ func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, erro
 	return finfo, nil
 }
 
+// fieldsByID returns the fields of tinfo indexed by the Element ID
+// they decode in def. Fields are matched by the ID in their tag first,
+// and then by name.
+func (tinfo *typeInfo) fieldsByID(def *Def) map[schema.ElementID]*fieldInfo {
+	if m, ok := def.fields.Load(tinfo); ok {
+		return m.(map[schema.ElementID]*fieldInfo)
+	}
+	m := make(map[schema.ElementID]*fieldInfo, len(tinfo.fields))
+	for i := range tinfo.fields {
+		finfo := &tinfo.fields[i]
+		if finfo.flags&fUnknown != 0 || finfo.id == 0 {
+			continue
+		}
+		if _, dup := m[finfo.id]; !dup {
+			m[finfo.id] = finfo
+		}
+	}
+	for i := range tinfo.fields {
+		finfo := &tinfo.fields[i]
+		if finfo.flags&fUnknown != 0 {
+			continue
+		}
+		for _, id := range def.names[finfo.name] {
+			if _, dup := m[id]; !dup {
+				m[id] = finfo
+			}
+		}
+	}
+	actual, _ := def.fields.LoadOrStore(tinfo, m)
+	return actual.(map[schema.ElementID]*fieldInfo)
+}
+
+// schema returns the element definition of a field selected by an ID
+// which is missing from the schema. It is built from the type hint of the
+// tag and the path of the parent.
+func (finfo *fieldInfo) schema(parent string) (schema.Element, bool) {
+	if finfo.id == 0 || finfo.typ == "" {
+		return schema.Element{}, false
+	}
+	return schema.Element{
+		Name: finfo.name,
+		Path: parent + "\\" + finfo.name,
+		ID:   finfo.id,
+		Type: finfo.typ,
+	}, true
+}
+
+// fieldByIndex returns the field of v with the given index sequence,
+// allocating nil embedded pointers on the way.
+func fieldByIndex(v reflect.Value, idx []int) (reflect.Value, error) {
+	for i, x := range idx {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("ebml: cannot set embedded pointer to unexported struct: %v", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// isEmptyValue reports whether v is omitted by the omitempty option.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
 var (
 	TypeInteger  = "integer"
 	TypeUinteger = "uinteger"
@@ -0,0 +1,255 @@
+package ebml
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type testTaggedInfo struct {
+	Name  string `ebml:"Title"`
+	Scale uint   `ebml:"0x2AD7B1"`
+	Skip  int    `ebml:"-"`
+}
+
+// Timestamps is exported so the decoder can allocate it when embedded.
+type Timestamps struct {
+	Timestamp uint
+}
+
+type testTaggedCluster struct {
+	*Timestamps
+	Extra   []RawElement `ebml:",unknown"`
+	Ignored struct{}     `ebml:",omitempty"`
+}
+
+type testTaggedDocument struct {
+	Meta    testTaggedInfo `ebml:"Info"`
+	Cluster testTaggedCluster
+}
+
+func TestGetTypeInfo_tags(t *testing.T) {
+	body := testElement(testIDTest,
+		testElement(testIDInfo,
+			testElement(testIDTitle, []byte("title")),
+			testElement(testIDTimestampScale, []byte{0x03, 0xe8}),
+			testElement(testIDOffset, []byte{0x05}),
+		),
+		testElement(testIDCluster,
+			testElement(testIDTimestamp, []byte{0x07}),
+			testElement(testIDPayload, []byte("abc")),
+		),
+	)
+	d := NewDecoder(bytes.NewReader(append(testHeader("test"), body...)))
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var got testTaggedDocument
+	if err := d.DecodeBody(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := testTaggedDocument{
+		Meta: testTaggedInfo{Name: "title", Scale: 1000},
+		Cluster: testTaggedCluster{
+			Timestamps: &Timestamps{Timestamp: 7},
+			Extra:      []RawElement{{ID: testIDPayload, Data: []byte("abc")}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeBody() = %+v, want %+v", got, want)
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.EncodeHeader(&EBML{DocType: "test", EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.EncodeBody(want); err != nil {
+		t.Fatal(err)
+	}
+	d = NewDecoder(bytes.NewReader(buf.Bytes()))
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var roundTrip testTaggedDocument
+	if err := d.DecodeBody(&roundTrip); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roundTrip, want) {
+		t.Errorf("round trip = %+v, want %+v", roundTrip, want)
+	}
+}
+
+func TestGetTypeInfo_inline(t *testing.T) {
+	var doc struct {
+		Info struct {
+			Names struct {
+				Title string
+			} `ebml:",inline"`
+			Duration float64 `ebml:",omitempty"`
+		}
+	}
+	doc.Info.Names.Title = "title"
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.EncodeHeader(&EBML{DocType: "test", EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8}); err != nil {
+		t.Fatal(err)
+	}
+	n := buf.Len()
+	if err := enc.Encode(testIDInfo, doc.Info); err != nil {
+		t.Fatal(err)
+	}
+	want := testElement(testIDInfo, testElement(testIDTitle, []byte("title")))
+	if got := buf.Bytes()[n:]; !bytes.Equal(got, want) {
+		t.Errorf("Encode() = %x, want %x", got, want)
+	}
+}
+
+func TestDecoder_Decode_idTags(t *testing.T) {
+	type private struct {
+		Flag uint `ebml:"id=0x7374,type=uinteger"`
+	}
+	type cluster struct {
+		Time    uint    `ebml:"id=0xE7"`
+		Private uint    `ebml:"id=0x7373,type=uinteger"`
+		Note    string  `ebml:"id=0x7375,type=utf-8"`
+		Nested  private `ebml:"id=0x7376,type=master"`
+	}
+	type document struct {
+		Clusters []cluster `ebml:"id=0x1F43B675"`
+		// No element has the ID of the tag, so Info is matched by name.
+		Info testInfo `ebml:"id=0x1234"`
+	}
+	body := testElement(testIDTest,
+		testElement(testIDInfo, testElement(testIDTitle, []byte("title"))),
+		testElement(testIDCluster,
+			testElement(testIDTimestamp, []byte{0x07}),
+			testElement(0x7373, []byte{0x01, 0x00}),
+			testElement(0x7375, []byte("note")),
+			testElement(0x7376, testElement(0x7374, []byte{0x02})),
+		),
+	)
+	d := NewDecoder(bytes.NewReader(append(testHeader("test"), body...)))
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var got document
+	if err := d.DecodeBody(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := document{
+		Clusters: []cluster{{Time: 7, Private: 256, Note: "note", Nested: private{Flag: 2}}},
+		Info:     testInfo{Title: "title", TimestampScale: 1000000, Offset: -1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeBody() = %+v, want %+v", got, want)
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.EncodeHeader(&EBML{DocType: "test", EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8}); err != nil {
+		t.Fatal(err)
+	}
+	n := buf.Len()
+	if err := enc.Encode(testIDCluster, want.Clusters[0]); err != nil {
+		t.Fatal(err)
+	}
+	wantCluster := testElement(testIDCluster,
+		testElement(testIDTimestamp, []byte{0x07}),
+		testElement(0x7373, []byte{0x01, 0x00}),
+		testElement(0x7375, []byte("note")),
+		testElement(0x7376, testElement(0x7374, []byte{0x02})),
+	)
+	if got := buf.Bytes()[n:]; !bytes.Equal(got, wantCluster) {
+		t.Errorf("Encode() = %x, want %x", got, wantCluster)
+	}
+}
+
+func TestDecoder_Decode_idTagWithoutType(t *testing.T) {
+	var doc struct {
+		Cluster struct {
+			Private uint `ebml:"id=0x7373"`
+		}
+	}
+	body := testElement(testIDTest, testElement(testIDCluster, testElement(0x7373, []byte{0x01})))
+	d := NewDecoder(bytes.NewReader(append(testHeader("test"), body...)))
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	err := d.DecodeBody(&doc)
+	var elErr *ElementError
+	if !errors.As(err, &elErr) || elErr.ID != 0x7373 {
+		t.Errorf("DecodeBody() error = %v, want *ElementError for 0x7373", err)
+	}
+}
+
+func TestGetTypeInfo_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{name: "unknown option", v: struct {
+			Title string `ebml:",bogus"`
+		}{}},
+		{name: "invalid ID", v: struct {
+			Title string `ebml:"0xZZ"`
+		}{}},
+		{name: "unknown field type", v: struct {
+			Extra [][]byte `ebml:",unknown"`
+		}{}},
+		{name: "multiple unknown fields", v: struct {
+			A []RawElement `ebml:",unknown"`
+			B []RawElement `ebml:",unknown"`
+		}{}},
+		{name: "unknown type", v: struct {
+			Title string `ebml:"id=0x7373,type=text"`
+		}{}},
+		{name: "type without ID", v: struct {
+			Title string `ebml:",type=utf-8"`
+		}{}},
+		{name: "inline non-struct", v: struct {
+			Title string `ebml:",inline"`
+		}{}},
+		{name: "multiple meta fields", v: struct {
+			Presence
+			Meta Meta
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := getTypeInfo(reflect.TypeOf(tt.v)); err == nil {
+				t.Error("getTypeInfo() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestGetTypeInfo_concurrent(t *testing.T) {
+	body := testElement(testIDTest,
+		testElement(testIDInfo, testElement(testIDTitle, []byte("title"))),
+	)
+	data := append(testHeader("test"), body...)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d := NewDecoder(bytes.NewReader(data))
+			if _, err := d.DecodeHeader(); err != nil {
+				t.Error(err)
+				return
+			}
+			var doc testDocument
+			if err := d.DecodeBody(&doc); err != nil {
+				t.Error(err)
+				return
+			}
+			if doc.Info.Title != "title" {
+				t.Errorf("Title = %q, want %q", doc.Info.Title, "title")
+			}
+		}()
+	}
+	wg.Wait()
+}
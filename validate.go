@@ -0,0 +1,765 @@
+package ebml
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/coding-socks/ebml/ebmltext"
+	"github.com/coding-socks/ebml/schema"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math/big"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Severity tells how serious an Issue is.
+type Severity int
+
+const (
+	// SeverityError marks a violation of the specification or the schema.
+	SeverityError Severity = iota
+	// SeverityWarning marks something a reader can cope with, such as an
+	// element missing from the schema.
+	SeverityWarning
+)
+
+var severityNames = []string{"error", "warning"}
+
+func (s Severity) String() string {
+	if int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return "Severity(" + strconv.Itoa(int(s)) + ")"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(b []byte) error {
+	i := slices.Index(severityNames, string(b))
+	if i < 0 {
+		return fmt.Errorf("ebml: unknown severity %q", b)
+	}
+	*s = Severity(i)
+	return nil
+}
+
+// An Issue is a problem found by Validate.
+//
+// Rule names the check which failed: "header", "doctype", "syntax",
+// "structure", "damaged", "toplevel", "trailing", "id", "path", "unknown",
+// "version", "unknownsize", "occurrence", "length", "value", "range",
+// "enum" or "crc32".
+type Issue struct {
+	Severity   Severity         `json:"severity"`
+	Rule       string           `json:"rule"`
+	Offset     int64            `json:"offset"`
+	ID         schema.ElementID `json:"id,omitempty"`
+	SchemaPath string           `json:"path,omitempty"`
+	Message    string           `json:"message"`
+}
+
+func (i Issue) String() string {
+	name := i.SchemaPath
+	if name == "" && i.ID != 0 {
+		name = i.ID.String()
+	}
+	if name == "" {
+		return fmt.Sprintf("%s at offset %d: %s: %s", i.Severity, i.Offset, i.Rule, i.Message)
+	}
+	return fmt.Sprintf("%s at offset %d: %s: %s: %s", i.Severity, i.Offset, name, i.Rule, i.Message)
+}
+
+// A Report holds the result of Validate.
+type Report struct {
+	DocType string `json:"docType"`
+	// Valid reports whether no issue has SeverityError.
+	Valid  bool    `json:"valid"`
+	Issues []Issue `json:"issues"`
+	// Truncated reports whether the validation stopped at MaxIssues.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ValidateOptions configures Validate.
+type ValidateOptions struct {
+	// MaxIssues stops the validation after that many issues. Zero means
+	// no limit.
+	MaxIssues int
+	// SkipCRC skips the verification of CRC-32 elements.
+	SkipCRC bool
+}
+
+// errStopValidation stops the walk of the document after a fatal issue
+// or MaxIssues issues.
+var errStopValidation = errors.New("ebml: validation stopped")
+
+// Validate reads the EBML Document from r and checks it against the
+// schema of its DocType. It reports every violation it finds instead of
+// stopping at the first one, unless the structure of the document cannot
+// be followed anymore.
+//
+// Validate checks the EBML Header, the position of each element, the
+// number of occurrences of elements, the ranges, lengths and enumerations
+// of values, master elements of unknown size, CRC-32 elements and data
+// after the EBML Root Element.
+//
+// The returned error is only set when r cannot be read.
+func Validate(r io.Reader, opts ValidateOptions) (Report, error) {
+	tap := &crcTap{r: r}
+	v := &validator{d: NewDecoder(tap), tap: tap, opts: opts}
+	tap.consumed = v.d.r.InputOffset
+	err := v.document()
+	if errors.Is(err, errStopValidation) {
+		err = nil
+	}
+	v.report.Valid = !slices.ContainsFunc(v.report.Issues, func(i Issue) bool {
+		return i.Severity == SeverityError
+	})
+	return v.report, err
+}
+
+type validator struct {
+	d      *Decoder
+	tap    *crcTap
+	opts   ValidateOptions
+	report Report
+
+	// hdr collects the values of the EBML Header.
+	hdr EBML
+}
+
+// add records an issue about el.
+func (v *validator) add(sev Severity, rule string, el Element, format string, args ...any) error {
+	v.report.Issues = append(v.report.Issues, Issue{
+		Severity:   sev,
+		Rule:       rule,
+		Offset:     el.Offset,
+		ID:         el.ID,
+		SchemaPath: el.Schema.Path,
+		Message:    fmt.Sprintf(format, args...),
+	})
+	if v.opts.MaxIssues > 0 && len(v.report.Issues) >= v.opts.MaxIssues {
+		v.report.Truncated = true
+		return errStopValidation
+	}
+	return nil
+}
+
+// fail records err as an issue when it describes the input, and stops the
+// validation. Other errors are returned as they are.
+func (v *validator) fail(err error) error {
+	var el Element
+	rule := "syntax"
+	if e := (*SyntaxError)(nil); errors.As(err, &e) {
+		el.Offset = e.Offset
+	} else if e := (*ElementError)(nil); errors.As(err, &e) {
+		el = Element{Offset: e.Offset, ID: e.ID, Schema: schema.Element{Path: e.SchemaPath}}
+		rule = "structure"
+		if errors.Is(err, ErrUnknownSizeNotAllowed) {
+			rule = "unknownsize"
+		}
+	} else if !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	} else {
+		el.Offset = v.d.r.InputOffset()
+	}
+	if err := v.add(SeverityError, rule, el, "%v", err); err != nil {
+		return err
+	}
+	return errStopValidation
+}
+
+// flushSkipped records the errors the Decoder recovered from.
+func (v *validator) flushSkipped() error {
+	errs := []error{v.d.skippedErrs}
+	if j, ok := v.d.skippedErrs.(interface{ Unwrap() []error }); ok {
+		errs = j.Unwrap()
+	}
+	v.d.skippedErrs = nil
+	for _, err := range errs {
+		var el Element
+		rule := "structure"
+		if e := (*DamagedDataError)(nil); errors.As(err, &e) {
+			el.Offset = e.Range.Start
+			rule = "damaged"
+		} else if e := (*ElementError)(nil); errors.As(err, &e) {
+			el = Element{Offset: e.Offset, ID: e.ID, Schema: schema.Element{Path: e.SchemaPath}}
+		} else if err == nil {
+			continue
+		}
+		if err := v.add(SeverityError, rule, el, "%v", err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *validator) document() error {
+	d := v.d
+	var header *EBML
+	rootSeen := false
+	for {
+		if err := v.flushSkipped(); err != nil {
+			return err
+		}
+		el, _, err := d.NextOf(RootEl, 0)
+		if isDamage(err) && rootSeen {
+			return v.add(SeverityError, "trailing", Element{Offset: d.r.InputOffset()}, "data after the EBML Root Element")
+		}
+		if isDamage(err) {
+			if err := d.recover(d.r.InputOffset(), err); err != nil && err != io.EOF {
+				return v.fail(err)
+			}
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return v.fail(err)
+		}
+		switch {
+		case el.ID == IDVoid:
+			err = d.Skip(el)
+		case el.ID == IDEBML && header == nil:
+			header, err = v.header(el)
+		case header == nil:
+			return v.add(SeverityError, "header", el, "the document does not start with an EBML Header")
+		case rootSeen:
+			return v.add(SeverityError, "trailing", el, "data after the EBML Root Element")
+		case el.ID == d.def.Root.ID:
+			rootSeen = true
+			err = v.element(el, RootEl)
+		default:
+			if err := v.add(SeverityError, "toplevel", el, "element is not allowed at the top level"); err != nil {
+				return err
+			}
+			err = d.Skip(el)
+		}
+		if err != nil {
+			return v.fail(err)
+		}
+	}
+	if err := v.flushSkipped(); err != nil {
+		return err
+	}
+	switch {
+	case header == nil:
+		return v.add(SeverityError, "header", Element{}, "the document has no EBML Header")
+	case !rootSeen:
+		return v.add(SeverityError, "toplevel", Element{}, "the document has no EBML Root Element %s", d.def.Root.Name)
+	}
+	return nil
+}
+
+// header checks the EBML Header el, and prepares the Decoder to read the
+// EBML Body.
+func (v *validator) header(el Element) (*EBML, error) {
+	d := v.d
+	d.def = HeaderDef
+	d.r.MaxIDLength = DefaultMaxIDLength
+	d.r.MaxSizeLength = DefaultMaxSizeLength
+	v.hdr = EBML{
+		EBMLVersion:        1,
+		EBMLReadVersion:    1,
+		EBMLMaxIDLength:    4,
+		EBMLMaxSizeLength:  8,
+		DocTypeVersion:     1,
+		DocTypeReadVersion: 1,
+	}
+	if err := v.element(el, RootEl); err != nil {
+		return nil, err
+	}
+	h := v.hdr
+	v.report.DocType = h.DocType
+	if h.EBMLMaxIDLength > 8 {
+		if err := v.add(SeverityError, "header", el, "EBMLMaxIDLength %d is not supported", h.EBMLMaxIDLength); err != nil {
+			return nil, err
+		}
+		h.EBMLMaxIDLength = 8
+	}
+	if h.EBMLMaxSizeLength > 8 {
+		if err := v.add(SeverityError, "header", el, "EBMLMaxSizeLength %d is not supported", h.EBMLMaxSizeLength); err != nil {
+			return nil, err
+		}
+		h.EBMLMaxSizeLength = 8
+	}
+	if h.DocTypeReadVersion > h.DocTypeVersion {
+		if err := v.add(SeverityError, "header", el, "DocTypeReadVersion %d is greater than DocTypeVersion %d", h.DocTypeReadVersion, h.DocTypeVersion); err != nil {
+			return nil, err
+		}
+	}
+	def, err := Definition(h.DocType)
+	if err != nil {
+		// Without a schema the EBML Body cannot be validated.
+		if err := v.add(SeverityError, "doctype", el, "%v", err); err != nil {
+			return nil, err
+		}
+		return nil, errStopValidation
+	}
+	d.def = def
+	d.r.MaxIDLength = max(h.EBMLMaxIDLength, 1)
+	d.r.MaxSizeLength = max(h.EBMLMaxSizeLength, 1)
+	return &h, nil
+}
+
+// element checks el, which was found inside parent, and its children.
+func (v *validator) element(el, parent Element) error {
+	d := v.d
+	if msg := invalidID(el.ID); msg != "" {
+		if err := v.add(SeverityError, "id", el, "%s", msg); err != nil {
+			return err
+		}
+	}
+	if el.Schema.Name == UnknownSchema.Name {
+		if err := v.add(SeverityWarning, "unknown", el, "element is not defined by the %s schema", d.def.Root.Name); err != nil {
+			return err
+		}
+		return d.Skip(el)
+	}
+	if parent.Schema.Path != RootEl.Schema.Path && !allowedIn(el, parent) {
+		if err := v.add(SeverityError, "path", el, "element is not allowed in %s", parent.Schema.Path); err != nil {
+			return err
+		}
+		return d.Skip(el)
+	}
+	if ver := int(v.hdr.DocTypeVersion); d.def != HeaderDef && (el.Schema.MinVer > ver || el.Schema.MaxVer != 0 && el.Schema.MaxVer < ver) {
+		if err := v.add(SeverityWarning, "version", el, "element is not defined in version %d of %s", ver, v.hdr.DocType); err != nil {
+			return err
+		}
+	}
+	if el.DataSize == -1 && !el.Schema.UnknownSizeAllowed {
+		if err := v.add(SeverityError, "unknownsize", el, "element must not have an unknown data size"); err != nil {
+			return err
+		}
+	}
+	if el.Schema.Type == TypeMaster {
+		return v.master(el)
+	}
+	return v.value(el)
+}
+
+// invalidID describes why id is not a valid Element ID according to
+// https://www.rfc-editor.org/rfc/rfc8794.html#section-5, or returns "".
+// IDs with all VINT_DATA bits set are rejected by the parser.
+func invalidID(id schema.ElementID) string {
+	w := max((bits.Len64(uint64(id))+7)/8, 1)
+	data := uint64(id) &^ (1 << (7 * w))
+	switch {
+	case data == 0:
+		return "the VINT_DATA of the Element ID is all zeros"
+	case w > 1 && data < 1<<(7*(w-1))-1:
+		return "the Element ID is not encoded in the shortest possible VINT"
+	}
+	return ""
+}
+
+// allowedIn reports whether the schema path of el allows it as a child
+// of parent.
+func allowedIn(el, parent Element) bool {
+	path := el.Schema.Path
+	if strings.Contains(path, "\\(") {
+		// Global elements are allowed at any level below the root.
+		return true
+	}
+	i := strings.LastIndex(path, "\\")
+	if path[:i] == parent.Schema.Path {
+		return true
+	}
+	// A recursive element can be a child of itself.
+	recursive := el.Schema.Recursive || strings.HasPrefix(path[i+1:], "+")
+	return recursive && el.ID == parent.ID
+}
+
+// master checks the children of the master element el.
+func (v *validator) master(el Element) error {
+	d := v.d
+	counts := make(map[schema.ElementID]int)
+	var sum *crcSum
+	var crc Element
+	var crcValue []byte
+	err := d.DecodeChildren(el, func(child Element) error {
+		if err := v.flushSkipped(); err != nil {
+			return err
+		}
+		v.tap.advance(child.Offset)
+		counts[child.ID]++
+		if child.ID != IDCRC32 {
+			return v.element(child, el)
+		}
+		first := len(counts) == 1 && counts[IDCRC32] == 1
+		b, err := v.read(child)
+		if err != nil || b == nil {
+			return err
+		}
+		switch {
+		case !first:
+			return v.add(SeverityError, "crc32", child, "CRC-32 must be the first child of %s", el.Schema.Name)
+		case len(b) == 4 && !v.opts.SkipCRC:
+			end := int64(-1)
+			if el.DataSize != -1 {
+				end = el.Offset + int64(el.HeaderSize) + el.DataSize
+			}
+			crc, crcValue = child, b
+			sum = v.tap.begin(d.r.InputOffset(), end)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := v.flushSkipped(); err != nil {
+		return err
+	}
+	if sum != nil {
+		end := sum.end
+		if end == -1 {
+			// The data of an unknown-sized element ends where the
+			// following element starts.
+			end = d.r.InputOffset()
+			if d.el != nil {
+				end = d.el.Offset
+			}
+		}
+		if got, want := v.tap.finish(sum, end), binary.LittleEndian.Uint32(crcValue); got != want {
+			err := v.add(SeverityError, "crc32", crc, "CRC-32 is 0x%08x, the data of %s has 0x%08x", want, el.Schema.Name, got)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for sch := range d.def.Children(el.Schema.Path) {
+		n := counts[sch.ID]
+		at := Element{Offset: el.Offset, ID: sch.ID, Schema: sch}
+		if n < sch.MinOccurs && !(n == 0 && sch.Default != nil) {
+			if err := v.add(SeverityError, "occurrence", at, "%s occurs %d times in %s, want at least %d", sch.Name, n, el.Schema.Name, sch.MinOccurs); err != nil {
+				return err
+			}
+		}
+		if limit := sch.MaxOccurs; !limit.Unbounded() && limit.Val() > 0 && n > limit.Val() {
+			if err := v.add(SeverityError, "occurrence", at, "%s occurs %d times in %s, want at most %d", sch.Name, n, el.Schema.Name, limit.Val()); err != nil {
+				return err
+			}
+		}
+	}
+	if n := counts[IDCRC32]; n > 1 {
+		sch, _ := d.def.Get(IDCRC32)
+		if err := v.add(SeverityError, "occurrence", Element{Offset: el.Offset, ID: IDCRC32, Schema: sch}, "CRC-32 occurs %d times in %s, want at most 1", n, el.Schema.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxValueSize limits the size of the values read by the validator.
+// Larger strings are only checked for their length, and binary values
+// are never read except for CRC-32.
+const maxValueSize = 1 << 20
+
+// read checks the length of the value of el and reads it. It returns a
+// nil slice without error when the value was skipped.
+func (v *validator) read(el Element) ([]byte, error) {
+	d := v.d
+	valid := true
+	switch t := el.Schema.Type; {
+	case t == TypeInteger || t == TypeUinteger:
+		valid = el.DataSize <= 8
+	case t == TypeFloat:
+		valid = el.DataSize == 0 || el.DataSize == 4 || el.DataSize == 8
+	case t == TypeDate:
+		valid = el.DataSize == 0 || el.DataSize == 8
+	}
+	if !valid {
+		if err := v.add(SeverityError, "length", el, "%d octets are not valid for a %s", el.DataSize, el.Schema.Type); err != nil {
+			return nil, err
+		}
+		return nil, d.Skip(el)
+	}
+	if el.Schema.Length != "" {
+		ok, err := inRange(el.Schema.Length, new(big.Float).SetInt64(el.DataSize))
+		switch {
+		case err != nil:
+			err = v.add(SeverityWarning, "length", el, "%v", err)
+		case !ok:
+			err = v.add(SeverityError, "length", el, "%d octets are out of the length %q", el.DataSize, el.Schema.Length)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if el.DataSize > maxValueSize || el.Schema.Type == TypeBinary && el.ID != IDCRC32 {
+		return nil, d.Skip(el)
+	}
+	b := make([]byte, el.DataSize)
+	if err := d.readData(el, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// value checks the non-master element el.
+func (v *validator) value(el Element) error {
+	b, err := v.read(el)
+	if err != nil || b == nil {
+		return err
+	}
+	// An empty element has the default value.
+	b = orDefault(el.Schema, b)
+	var x *big.Float
+	var enum string
+	switch el.Schema.Type {
+	case TypeInteger, TypeDate:
+		// A date is checked as the number of nanoseconds since the
+		// EBML epoch.
+		i, _ := ebmltext.Int(b)
+		x, enum = new(big.Float).SetInt64(i), strconv.FormatInt(i, 10)
+	case TypeUinteger:
+		u, _ := ebmltext.Uint(b)
+		x, enum = new(big.Float).SetUint64(u), strconv.FormatUint(u, 10)
+		v.headerValue(el, u, "")
+	case TypeFloat:
+		f, _ := ebmltext.Float(b)
+		if f == f { // a NaN is out of any range
+			x = new(big.Float).SetFloat64(f)
+		}
+		enum = strconv.FormatFloat(f, 'g', -1, 64)
+	case TypeString, TypeUTF8:
+		str, _ := ebmltext.String(b)
+		// Octets after a null octet are padding.
+		str, _, _ = strings.Cut(str, "\x00")
+		if !validText(str, el.Schema.Type) {
+			if err := v.add(SeverityError, "value", el, "value %q is not a valid %s", str, el.Schema.Type); err != nil {
+				return err
+			}
+		}
+		enum = str
+		v.headerValue(el, 0, str)
+	}
+	if r := el.Schema.Range; r != "" && el.Schema.Type != TypeString && el.Schema.Type != TypeUTF8 {
+		ok := x != nil
+		if x != nil {
+			ok, err = inRange(r, x)
+		}
+		if err != nil {
+			return v.add(SeverityWarning, "range", el, "%v", err)
+		}
+		if !ok {
+			if err := v.add(SeverityError, "range", el, "value %s is out of the range %q", enum, r); err != nil {
+				return err
+			}
+		}
+	}
+	if el.Schema.Restriction != nil && len(el.Schema.Restriction.Enum) > 0 {
+		if !slices.ContainsFunc(el.Schema.Restriction.Enum, func(e schema.Enum) bool {
+			return enumEqual(e.Value, enum, x)
+		}) {
+			return v.add(SeverityWarning, "enum", el, "value %s is not one of the values defined by the schema", enum)
+		}
+	}
+	return nil
+}
+
+// headerValue records the value of an element of the EBML Header.
+func (v *validator) headerValue(el Element, u uint64, s string) {
+	if v.d.def != HeaderDef {
+		return
+	}
+	switch el.ID {
+	case IDEBMLVersion:
+		v.hdr.EBMLVersion = uint(u)
+	case IDEBMLReadVersion:
+		v.hdr.EBMLReadVersion = uint(u)
+	case IDEBMLMaxIDLength:
+		v.hdr.EBMLMaxIDLength = uint(u)
+	case IDEBMLMaxSizeLength:
+		v.hdr.EBMLMaxSizeLength = uint(u)
+	case IDDocType:
+		v.hdr.DocType = s
+	case IDDocTypeVersion:
+		v.hdr.DocTypeVersion = uint(u)
+	case IDDocTypeReadVersion:
+		v.hdr.DocTypeReadVersion = uint(u)
+	}
+}
+
+// validText reports whether str only holds printable ASCII characters for
+// a string, or valid UTF-8 for an utf-8 string.
+func validText(str, typ string) bool {
+	if typ == TypeUTF8 {
+		return utf8.ValidString(str)
+	}
+	for i := 0; i < len(str); i++ {
+		if str[i] < 0x20 || str[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// enumEqual reports whether the value of an element matches the value e
+// of an enumeration. Numbers are compared by value.
+func enumEqual(e, s string, x *big.Float) bool {
+	if x == nil {
+		return e == s
+	}
+	y, _, err := big.ParseFloat(strings.TrimSpace(e), 0, rangePrec, big.ToNearestEven)
+	return err == nil && x.Cmp(y) == 0
+}
+
+const rangePrec = 128
+
+// inRange reports whether x is inside the range expression r of a schema.
+// The expression is a comma separated list of conditions which must all
+// hold. A condition is a number, "not" followed by a number, a comparison
+// operator followed by a number, or an inclusive interval "a-b".
+func inRange(r string, x *big.Float) (bool, error) {
+	for _, cond := range strings.Split(r, ",") {
+		cond = strings.TrimSpace(cond)
+		var op string
+		for _, o := range []string{">=", "<=", ">", "<", "not "} {
+			if strings.HasPrefix(cond, o) {
+				op, cond = o, strings.TrimSpace(cond[len(o):])
+				break
+			}
+		}
+		if op == "" {
+			if i := intervalDash(cond); i > 0 {
+				lo, err1 := parseRangeNumber(cond[:i])
+				hi, err2 := parseRangeNumber(cond[i+1:])
+				if err := errors.Join(err1, err2); err != nil {
+					return false, fmt.Errorf("ebml: invalid range %q: %w", r, err)
+				}
+				if x.Cmp(lo) < 0 || x.Cmp(hi) > 0 {
+					return false, nil
+				}
+				continue
+			}
+		}
+		y, err := parseRangeNumber(cond)
+		if err != nil {
+			return false, fmt.Errorf("ebml: invalid range %q: %w", r, err)
+		}
+		c := x.Cmp(y)
+		var ok bool
+		switch op {
+		case "":
+			ok = c == 0
+		case "not ":
+			ok = c != 0
+		case ">=":
+			ok = c >= 0
+		case "<=":
+			ok = c <= 0
+		case ">":
+			ok = c > 0
+		case "<":
+			ok = c < 0
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// intervalDash returns the index of the dash separating the bounds of an
+// interval, or -1. A dash can also be the sign of the lower bound or of
+// an exponent.
+func intervalDash(s string) int {
+	for i := 1; i < len(s); i++ {
+		if s[i] != '-' {
+			continue
+		}
+		switch prev := s[i-1]; {
+		case prev == 'p' || prev == 'P':
+			continue
+		case (prev == 'e' || prev == 'E') && !strings.HasPrefix(strings.ToLower(strings.TrimLeft(s, "-")), "0x"):
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+func parseRangeNumber(s string) (*big.Float, error) {
+	f, _, err := big.ParseFloat(strings.TrimSpace(s), 0, rangePrec, big.ToNearestEven)
+	return f, err
+}
+
+// headerLag is the largest Element ID and Element Data Size. The data of
+// an unknown-sized element ends before the header of the element which
+// does not fit into it, so that many octets are held back from the sums
+// with an unknown end.
+const headerLag = 8 + 8
+
+// A crcTap keeps the input read by the Decoder until it is added to the
+// CRC-32 sums of the master elements being validated.
+type crcTap struct {
+	r        io.Reader
+	consumed func() int64 // input offset consumed by the Decoder
+
+	pos  int64 // input offset of buf[0]
+	buf  []byte
+	sums []*crcSum
+}
+
+type crcSum struct {
+	h   hash.Hash32
+	at  int64 // input offset up to which h is computed
+	end int64 // input offset where the sum ends, or -1 when unknown
+}
+
+func (t *crcTap) Read(p []byte) (int, error) {
+	if t.consumed != nil {
+		t.advance(t.consumed() - headerLag)
+	}
+	n, err := t.r.Read(p)
+	t.buf = append(t.buf, p[:n]...)
+	return n, err
+}
+
+// begin starts a sum at the input offset start.
+func (t *crcTap) begin(start, end int64) *crcSum {
+	s := &crcSum{h: crc32.NewIEEE(), at: start, end: end}
+	t.sums = append(t.sums, s)
+	return s
+}
+
+// finish completes s at the input offset end and returns its value.
+func (t *crcTap) finish(s *crcSum, end int64) uint32 {
+	s.end = end
+	t.advance(end)
+	t.sums = slices.DeleteFunc(t.sums, func(x *crcSum) bool { return x == s })
+	return s.h.Sum32()
+}
+
+// advance adds the input before offset to the sums, and discards the
+// input which is not needed anymore. The caller guarantees that offset
+// is inside the data of every sum with an unknown end.
+func (t *crcTap) advance(offset int64) {
+	keep := offset
+	for _, s := range t.sums {
+		to := offset
+		if s.end != -1 {
+			to = min(to, s.end)
+		}
+		to = min(to, t.pos+int64(len(t.buf)))
+		if s.at < to {
+			s.h.Write(t.buf[s.at-t.pos : to-t.pos])
+			s.at = to
+		}
+		if s.end == -1 || s.at < s.end {
+			keep = min(keep, s.at)
+		}
+	}
+	if n := min(keep-t.pos, int64(len(t.buf))); n > 0 {
+		t.buf = append(t.buf[:0], t.buf[n:]...)
+		t.pos += n
+	}
+}
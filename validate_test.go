@@ -0,0 +1,262 @@
+package ebml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/ebml/schema"
+)
+
+// testCRC returns a CRC-32 element for the given children.
+func testCRC(data ...[]byte) []byte {
+	return testElement(IDCRC32, binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(bytes.Join(data, nil))))
+}
+
+func testValidInfo(children ...[]byte) []byte {
+	return testElement(testIDInfo, append([][]byte{testElement(testIDTitle, []byte("title"))}, children...)...)
+}
+
+func TestValidate(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.EncodeHeader(&EBML{EBMLVersion: 1, EBMLReadVersion: 1, EBMLMaxIDLength: 4, EBMLMaxSizeLength: 8, DocType: "test", DocTypeVersion: 1, DocTypeReadVersion: 1}); err != nil {
+		t.Fatal(err)
+	}
+	doc := testDocument{
+		Info: testInfo{
+			Title:          "title",
+			TimestampScale: 1000000,
+			Duration:       12.5,
+			DateUTC:        time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Offset:         -1,
+		},
+		Cluster: []testCluster{{Timestamp: 1, Payload: [][]byte{{1, 2}}}},
+	}
+	if err := enc.EncodeBody(doc); err != nil {
+		t.Fatal(err)
+	}
+	report, err := Validate(&buf, ValidateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || len(report.Issues) != 0 || report.DocType != "test" {
+		t.Errorf("Validate() = %+v, want a valid report without issues", report)
+	}
+}
+
+func TestValidate_issues(t *testing.T) {
+	ts := testElement(testIDTimestamp, []byte{1})
+	tests := []struct {
+		name     string
+		body     []byte
+		severity Severity
+		rule     string
+		path     string
+	}{
+		{"range", testElement(testIDTest, testElement(testIDInfo, testElement(testIDTimestampScale, []byte{0}))),
+			SeverityError, "range", `\Test\Info\TimestampScale`},
+		{"float range", testElement(testIDTest, testValidInfo(testElement(testIDDuration, []byte{0xbf, 0x80, 0, 0}))),
+			SeverityError, "range", `\Test\Info\Duration`},
+		{"float length", testElement(testIDTest, testValidInfo(testElement(testIDDuration, []byte{1, 2}))),
+			SeverityError, "length", `\Test\Info\Duration`},
+		{"max occurrence", testElement(testIDTest, testValidInfo(testElement(testIDTitle, []byte("again")))),
+			SeverityError, "occurrence", `\Test\Info\Title`},
+		{"min occurrence", testElement(testIDTest, testValidInfo(), testElement(testIDCluster)),
+			SeverityError, "occurrence", `\Test\Cluster\Timestamp`},
+		{"missing master", testElement(testIDTest, testElement(testIDCluster, ts)),
+			SeverityError, "occurrence", `\Test\Info`},
+		{"path", testElement(testIDTest, testValidInfo(testElement(testIDPayload, []byte{1}))),
+			SeverityError, "path", `\Test\Cluster\Payload`},
+		{"unknown element", testElement(testIDTest, testValidInfo(testElement(0x4080, []byte{1}))),
+			SeverityWarning, "unknown", ``},
+		{"unknown size", testElement(testIDTest, testUnknownSizeElement(testIDInfo, testElement(testIDTitle, []byte("title"))), testElement(testIDCluster, ts)),
+			SeverityError, "unknownsize", `\Test\Info`},
+		{"utf-8", testElement(testIDTest, testElement(testIDInfo, testElement(testIDTitle, []byte{0xff}))),
+			SeverityError, "value", `\Test\Info\Title`},
+		{"crc", testElement(testIDTest, testElement(testIDInfo, testElement(IDCRC32, []byte{1, 2, 3, 4}), testElement(testIDTitle, []byte("title")))),
+			SeverityError, "crc32", `\(1-\)CRC-32`},
+		{"crc position", testElement(testIDTest, testValidInfo(testCRC())),
+			SeverityError, "crc32", `\(1-\)CRC-32`},
+		{"trailing", append(testElement(testIDTest, testValidInfo()), 0xff, 0xff, 0x00),
+			SeverityError, "trailing", ``},
+		{"trailing element", append(testElement(testIDTest, testValidInfo()), testElement(testIDTest, testValidInfo())...),
+			SeverityError, "trailing", `\Test`},
+		{"no root", nil,
+			SeverityError, "toplevel", ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := append(testHeader("test"), tt.body...)
+			report, err := Validate(bytes.NewReader(doc), ValidateOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Valid != (tt.severity == SeverityWarning) {
+				t.Errorf("Valid = %v", report.Valid)
+			}
+			if len(report.Issues) != 1 {
+				t.Fatalf("Issues = %v, want one", report.Issues)
+			}
+			if i := report.Issues[0]; i.Severity != tt.severity || i.Rule != tt.rule || i.SchemaPath != tt.path {
+				t.Errorf("Issue = %+v, want %v %q at %q", i, tt.severity, tt.rule, tt.path)
+			}
+		})
+	}
+}
+
+func TestValidate_crc(t *testing.T) {
+	title := testElement(testIDTitle, []byte("title"))
+	ts := testElement(testIDTimestamp, []byte{1})
+	payload := testElement(testIDPayload, bytes.Repeat([]byte{7}, 3000))
+	doc := append(testHeader("test"), testUnknownSizeElement(testIDTest,
+		testElement(testIDInfo, testCRC(title), title),
+		testUnknownSizeElement(testIDCluster, testCRC(ts, payload), ts, payload),
+		testUnknownSizeElement(testIDCluster, testCRC(ts), ts),
+	)...)
+	report, err := Validate(bytes.NewReader(doc), ValidateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || len(report.Issues) != 0 {
+		t.Errorf("Validate() = %+v, want a valid report without issues", report)
+	}
+
+	i := bytes.Index(doc, bytes.Repeat([]byte{7}, 3000))
+	doc[i+1500] = 8
+	report, err = Validate(bytes.NewReader(doc), ValidateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || len(report.Issues) != 1 || report.Issues[0].Rule != "crc32" {
+		t.Errorf("Validate() = %+v, want a crc32 issue", report)
+	}
+}
+
+func TestValidate_header(t *testing.T) {
+	header := testElement(IDEBML,
+		testElement(IDEBMLReadVersion, []byte{2}),
+		testElement(IDDocType, []byte("test")),
+		testElement(IDDocTypeVersion, []byte{1}),
+		testElement(IDDocTypeReadVersion, []byte{2}),
+	)
+	doc := append(header, testElement(testIDTest, testValidInfo())...)
+	report, err := Validate(bytes.NewReader(doc), ValidateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules []string
+	for _, i := range report.Issues {
+		rules = append(rules, i.Rule)
+	}
+	if got, want := strings.Join(rules, ","), "range,header"; got != want {
+		t.Errorf("rules = %v, want %v", got, want)
+	}
+
+	report, err = Validate(bytes.NewReader(testHeader("unknown")), ValidateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || len(report.Issues) != 1 || report.Issues[0].Rule != "doctype" {
+		t.Errorf("Validate() = %+v, want a doctype issue", report)
+	}
+
+	report, err = Validate(bytes.NewReader(testElement(testIDTest)), ValidateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || len(report.Issues) != 1 || report.Issues[0].Rule != "header" {
+		t.Errorf("Validate() = %+v, want a header issue", report)
+	}
+}
+
+func TestValidate_maxIssues(t *testing.T) {
+	unknown := testElement(0x4080, []byte{1})
+	doc := append(testHeader("test"), testElement(testIDTest, testValidInfo(unknown, unknown, unknown))...)
+	report, err := Validate(bytes.NewReader(doc), ValidateOptions{MaxIssues: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Truncated || len(report.Issues) != 2 {
+		t.Errorf("Validate() = %+v, want 2 issues and truncated", report)
+	}
+}
+
+func TestReport_json(t *testing.T) {
+	r := Report{DocType: "test", Issues: []Issue{{Severity: SeverityWarning, Rule: "unknown", Offset: 3, ID: 0x4001, Message: "m"}}}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"docType":"test","valid":false,"issues":[{"severity":"warning","rule":"unknown","offset":3,"id":"0x4001","message":"m"}]}`
+	if string(b) != want {
+		t.Errorf("Marshal() = %s, want %s", b, want)
+	}
+	var got Report
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Issues[0].Severity != SeverityWarning || got.Issues[0].ID != schema.ElementID(0x4001) {
+		t.Errorf("Unmarshal() = %+v", got)
+	}
+}
+
+func TestInRange(t *testing.T) {
+	tests := []struct {
+		r    string
+		x    float64
+		want bool
+	}{
+		{"not 0", 0, false},
+		{"not 0", 1, true},
+		{"1", 1, true},
+		{"1", 2, false},
+		{">=4", 4, true},
+		{">=4", 3, false},
+		{"> 0x0p+0", 0, false},
+		{"> 0x0p+0", 0.5, true},
+		{"0-1", 1, true},
+		{"0-1", 2, false},
+		{"-1-1", -1, true},
+		{"0x0p+0-0x1p+0", 0.5, true},
+		{"1e-2-1e+2", 0.001, false},
+		{">= 0x0p+0, <= 0x1p+0", 1.5, false},
+		{">0, <10, not 5", 5, false},
+	}
+	for _, tt := range tests {
+		got, err := inRange(tt.r, new(big.Float).SetFloat64(tt.x))
+		if err != nil || got != tt.want {
+			t.Errorf("inRange(%q, %v) = %v, %v, want %v", tt.r, tt.x, got, err, tt.want)
+		}
+	}
+	if _, err := inRange("abc", new(big.Float)); err == nil {
+		t.Error("inRange(abc) error = nil")
+	}
+}
+
+func TestValidate_invalidID(t *testing.T) {
+	// 0x4001 is the two octet form of 0x81, which an RFC 8794 reader
+	// must not accept as a distinct Element ID.
+	doc := append(testHeader("test"), testElement(testIDTest, testValidInfo(testElement(0x4001, []byte{1})))...)
+	report, err := Validate(bytes.NewReader(doc), ValidateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(report.Issues, func(i Issue) bool { return i.Rule == "id" && i.Severity == SeverityError }) {
+		t.Errorf("Issues = %v, want an id error", report.Issues)
+	}
+}
+
+func TestInvalidID(t *testing.T) {
+	for id, want := range map[schema.ElementID]bool{0x80: true, 0x81: false, 0x4000: true, 0x4001: true, 0x407f: false, 0x4080: false, 0x1a45dfa3: false, 0x101fffff: false, 0x100fffff: true} {
+		if got := invalidID(id) != ""; got != want {
+			t.Errorf("invalidID(%v) = %q, want invalid %v", id, invalidID(id), want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package ebml
+
+import (
+	"errors"
+	"github.com/coding-socks/ebml/schema"
+	"slices"
+)
+
+// ErrAborted is returned by the methods of a Decoder after its Visitor
+// returned VisitAbort.
+var ErrAborted = errors.New("ebml: aborted by the visitor")
+
+// A VisitAction tells a Decoder how to go on after a call of its Visitor.
+type VisitAction int
+
+const (
+	// VisitContinue reads the element as usual.
+	VisitContinue VisitAction = iota
+	// VisitSkipData skips the data of the element without decoding it.
+	// The children of a master element are still visited, but they are
+	// not decoded either.
+	VisitSkipData
+	// VisitSkipSubtree skips the element together with its children,
+	// which are not visited.
+	VisitSkipSubtree
+	// VisitAbort stops the decoding, the Decoder returns ErrAborted.
+	VisitAbort
+)
+
+// A Visitor observes the elements read by a Decoder, and controls how
+// they are read. The path holds the ancestors of el, starting with the
+// top-level element. It is only valid during the call.
+type Visitor interface {
+	// Visit is called when the header of el is read, before its data.
+	Visit(path []Element, el Element) VisitAction
+	// Decoded is called when the value of el is decoded. Only
+	// VisitAbort has an effect, the other actions continue.
+	Decoded(path []Element, el Element, val any) VisitAction
+}
+
+// VisitFunc is a Visitor which calls itself for Visit, and which ignores
+// the decoded values.
+type VisitFunc func(path []Element, el Element) VisitAction
+
+func (f VisitFunc) Visit(path []Element, el Element) VisitAction {
+	return f(path, el)
+}
+
+func (f VisitFunc) Decoded(path []Element, el Element, val any) VisitAction {
+	return VisitContinue
+}
+
+// A Filter selects the elements which trigger a Visitor, by ID or by
+// schema path such as `\Segment\Cluster`. An element is selected when it
+// matches one of the IDs or one of the paths. The zero Filter selects
+// every element.
+type Filter struct {
+	IDs   []schema.ElementID
+	Paths []string
+}
+
+func (f Filter) match(el Element) bool {
+	if len(f.IDs) == 0 && len(f.Paths) == 0 {
+		return true
+	}
+	return slices.Contains(f.IDs, el.ID) || slices.Contains(f.Paths, el.Schema.Path)
+}
+
+// SetVisitor sets the Visitor of d, which is called for the elements
+// selected by f. Elements which are not selected are read as usual.
+// A nil Visitor removes the current one.
+//
+// The elements are visited by NextOf, so all the methods of d which read
+// elements trigger v, including Skip for a master element of unknown
+// size. The fields of skipped elements are not decoded and do not get a
+// default value, Presence and Meta record them as present.
+func (d *Decoder) SetVisitor(v Visitor, f Filter) {
+	d.visitor = v
+	d.filter = f
+}
+
+// visit calls the Visitor of d for el, read by NextOf from parent at
+// offset, and applies the returned action. It reports whether el was
+// skipped. overflow tells that el does not fit into parent.
+func (d *Decoder) visit(parent Element, offset int64, el Element, n int, overflow error) (bool, error) {
+	if d.visitor == nil || d.quiet > 0 || !d.filter.match(el) {
+		return false, nil
+	}
+	action := d.visitor.Visit(d.ancestors, el)
+	switch action {
+	case VisitAbort:
+		return false, ErrAborted
+	case VisitSkipData, VisitSkipSubtree:
+	default:
+		return false, nil
+	}
+	if el.DataSize == -1 && el.Schema.Type != TypeMaster {
+		// It is up to the caller to handle the invalid element.
+		return false, nil
+	}
+	if overflow != nil {
+		el.DataSize = max(parent.DataSize-offset-int64(n), 0)
+		d.skippedErrs = errors.Join(d.skippedErrs, overflow)
+	}
+	if d.onSkip != nil {
+		d.onSkip(parent, el)
+	}
+	if action == VisitSkipData && el.Schema.Type == TypeMaster {
+		return true, d.discard(el)
+	}
+	d.quiet++
+	err := d.Skip(el)
+	d.quiet--
+	return true, err
+}
+
+// discard consumes el without decoding it. The children of a master
+// element are still visited.
+func (d *Decoder) discard(el Element) error {
+	if el.Schema.Type == TypeMaster {
+		return d.DecodeChildren(el, d.discard)
+	}
+	return d.Skip(el)
+}
+
+// decoded calls the Visitor of d for a decoded value.
+func (d *Decoder) decoded(el Element, val any) error {
+	if d.visitor == nil || d.quiet > 0 || !d.filter.match(el) {
+		return nil
+	}
+	if d.visitor.Decoded(d.ancestors, el, val) == VisitAbort {
+		return ErrAborted
+	}
+	return nil
+}
+
+// Callbacker is the former interface of the callbacks of a Decoder. The
+// returned Callbacker replaces the current one, nil stops the calls.
+//
+// Deprecated: Use Visitor, which also receives the ancestors of the
+// elements and controls the decoding.
+type Callbacker interface {
+	// Found is called whenever a new element is found in the target io.Reader.
+	Found(el Element, offset int64, headerSize int) Callbacker
+	// Decoded is called whenever an element is decoded from the target io.Reader.
+	Decoded(el Element, offset int64, headerSize int, val any) Callbacker
+}
+
+// SetCallback sets a Callbacker which is triggered when NextOf reads
+// an element id and data size, and when a value is successfully decoded.
+//
+// Deprecated: Use SetVisitor.
+func (d *Decoder) SetCallback(c Callbacker) {
+	if c == nil {
+		d.SetVisitor(nil, Filter{})
+		return
+	}
+	d.SetVisitor(&callbackVisitor{c: c}, Filter{})
+}
+
+// callbackVisitor adapts a Callbacker to a Visitor.
+type callbackVisitor struct {
+	c Callbacker
+}
+
+func (v *callbackVisitor) Visit(path []Element, el Element) VisitAction {
+	if v.c != nil {
+		v.c = v.c.Found(el, el.Offset, el.HeaderSize)
+	}
+	return VisitContinue
+}
+
+func (v *callbackVisitor) Decoded(path []Element, el Element, val any) VisitAction {
+	if v.c != nil {
+		v.c = v.c.Decoded(el, el.Offset, el.HeaderSize, val)
+	}
+	return VisitContinue
+}
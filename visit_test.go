@@ -0,0 +1,210 @@
+package ebml
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/coding-socks/ebml/schema"
+)
+
+func testVisitDocument() []byte {
+	return append(testHeader("test"), testElement(testIDTest,
+		testElement(testIDInfo, testElement(testIDTitle, []byte("a"))),
+		testElement(testIDCluster, testElement(testIDTimestamp, []byte{1})),
+		testElement(testIDCluster, testElement(testIDTimestamp, []byte{2})),
+	)...)
+}
+
+// testVisitor records the visited elements as their path.
+type testVisitor struct {
+	visited []string
+	decoded []any
+	actions map[string]VisitAction
+}
+
+func (v *testVisitor) Visit(path []Element, el Element) VisitAction {
+	var names []string
+	for _, p := range path {
+		names = append(names, p.Schema.Name)
+	}
+	name := strings.Join(append(names, el.Schema.Name), "/")
+	v.visited = append(v.visited, name)
+	return v.actions[el.Schema.Name]
+}
+
+func (v *testVisitor) Decoded(path []Element, el Element, val any) VisitAction {
+	if el.Schema.Type != TypeMaster {
+		v.decoded = append(v.decoded, val)
+	}
+	return v.actions["decoded "+el.Schema.Name]
+}
+
+func TestDecoder_SetVisitor(t *testing.T) {
+	decode := func(t *testing.T, v Visitor, f Filter) (testDocument, error) {
+		t.Helper()
+		d := NewDecoder(bytes.NewReader(testVisitDocument()))
+		if _, err := d.DecodeHeader(); err != nil {
+			t.Fatal(err)
+		}
+		d.SetVisitor(v, f)
+		var doc testDocument
+		err := d.DecodeBody(&doc)
+		return doc, err
+	}
+
+	t.Run("path", func(t *testing.T) {
+		v := &testVisitor{}
+		if _, err := decode(t, v, Filter{}); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			"Test",
+			"Test/Info", "Test/Info/Title",
+			"Test/Cluster", "Test/Cluster/Timestamp",
+			"Test/Cluster", "Test/Cluster/Timestamp",
+		}
+		if !slices.Equal(v.visited, want) {
+			t.Errorf("visited %q, want %q", v.visited, want)
+		}
+	})
+	t.Run("filter", func(t *testing.T) {
+		v := &testVisitor{}
+		f := Filter{IDs: []schema.ElementID{testIDTitle}, Paths: []string{`\Test\Cluster\Timestamp`}}
+		if _, err := decode(t, v, f); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"Test/Info/Title", "Test/Cluster/Timestamp", "Test/Cluster/Timestamp"}
+		if !slices.Equal(v.visited, want) {
+			t.Errorf("visited %q, want %q", v.visited, want)
+		}
+		if wantDecoded := []any{"a", uint(1), uint(2)}; !slices.Equal(v.decoded, wantDecoded) {
+			t.Errorf("decoded %v, want %v", v.decoded, wantDecoded)
+		}
+	})
+	t.Run("skip subtree", func(t *testing.T) {
+		v := &testVisitor{actions: map[string]VisitAction{"Cluster": VisitSkipSubtree}}
+		doc, err := decode(t, v, Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(doc.Cluster) != 0 {
+			t.Errorf("Cluster = %+v, want none", doc.Cluster)
+		}
+		want := []string{"Test", "Test/Info", "Test/Info/Title", "Test/Cluster", "Test/Cluster"}
+		if !slices.Equal(v.visited, want) {
+			t.Errorf("visited %q, want %q", v.visited, want)
+		}
+	})
+	t.Run("skip data", func(t *testing.T) {
+		v := &testVisitor{actions: map[string]VisitAction{"Info": VisitSkipData}}
+		doc, err := decode(t, v, Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.Info.Title != "" {
+			t.Errorf("Title = %q, want it not decoded", doc.Info.Title)
+		}
+		if !slices.Contains(v.visited, "Test/Info/Title") {
+			t.Errorf("visited %q, want the children of Info", v.visited)
+		}
+		if len(doc.Cluster) != 2 {
+			t.Errorf("got %d Clusters, want 2", len(doc.Cluster))
+		}
+	})
+	t.Run("abort", func(t *testing.T) {
+		v := &testVisitor{actions: map[string]VisitAction{"decoded Timestamp": VisitAbort}}
+		doc, err := decode(t, v, Filter{})
+		if !errors.Is(err, ErrAborted) {
+			t.Fatalf("DecodeBody() error = %v, want ErrAborted", err)
+		}
+		if len(doc.Cluster) != 1 || doc.Cluster[0].Timestamp != 1 {
+			t.Errorf("Cluster = %+v, want the first one", doc.Cluster)
+		}
+	})
+}
+
+func TestDecoder_SetVisitor_meta(t *testing.T) {
+	type info struct {
+		Meta
+		Title          string
+		TimestampScale uint
+	}
+	var doc struct {
+		Meta
+		Info info
+	}
+	body := testElement(testIDTest, testElement(testIDInfo,
+		testElement(testIDTitle, []byte("a")),
+		testElement(testIDTimestampScale, []byte{0x03, 0xe8}),
+	))
+	decode := func(t *testing.T, action VisitAction, ids ...schema.ElementID) {
+		t.Helper()
+		d := NewDecoder(bytes.NewReader(append(testHeader("test"), body...)))
+		if _, err := d.DecodeHeader(); err != nil {
+			t.Fatal(err)
+		}
+		d.SetVisitor(VisitFunc(func(path []Element, el Element) VisitAction {
+			return action
+		}), Filter{IDs: ids})
+		doc.Info = info{}
+		if err := d.DecodeBody(&doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decode(t, VisitContinue)
+	scale, _ := doc.Info.Meta.Info("TimestampScale")
+	infoEl, _ := doc.Meta.Info("Info")
+
+	t.Run("skip data", func(t *testing.T) {
+		decode(t, VisitSkipData, testIDTimestampScale)
+		if doc.Info.TimestampScale != 0 {
+			t.Errorf("TimestampScale = %d, want 0 instead of the default", doc.Info.TimestampScale)
+		}
+		if got, ok := doc.Info.Meta.Info("TimestampScale"); !ok || got != scale {
+			t.Errorf("Info(%q) = %+v, %v, want %+v", "TimestampScale", got, ok, scale)
+		}
+	})
+	t.Run("skip subtree", func(t *testing.T) {
+		decode(t, VisitSkipSubtree, testIDInfo)
+		if doc.Info.TimestampScale != 0 {
+			t.Errorf("TimestampScale = %d, want 0 instead of the default", doc.Info.TimestampScale)
+		}
+		if got, ok := doc.Meta.Info("Info"); !ok || got != infoEl {
+			t.Errorf("Info(%q) = %+v, %v, want %+v", "Info", got, ok, infoEl)
+		}
+	})
+}
+
+type testCallbacker struct {
+	found, decoded int
+}
+
+func (c *testCallbacker) Found(el Element, offset int64, headerSize int) Callbacker {
+	c.found++
+	return c
+}
+
+func (c *testCallbacker) Decoded(el Element, offset int64, headerSize int, val any) Callbacker {
+	c.decoded++
+	return c
+}
+
+func TestDecoder_SetCallback(t *testing.T) {
+	d := NewDecoder(bytes.NewReader(testVisitDocument()))
+	c := &testCallbacker{}
+	d.SetCallback(c)
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var doc testDocument
+	if err := d.DecodeBody(&doc); err != nil {
+		t.Fatal(err)
+	}
+	// The EBML Header has 4 elements, the EBML Body 7.
+	if c.found != 11 || c.decoded != 11 {
+		t.Errorf("found %d and decoded %d elements, want 11 and 11", c.found, c.decoded)
+	}
+}
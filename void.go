@@ -0,0 +1,128 @@
+package ebml
+
+import (
+	"errors"
+	"fmt"
+	"github.com/coding-socks/ebml/ebmltext"
+	"io"
+)
+
+// ErrNoSpace signals that an element does not fit into the space
+// available for it.
+var ErrNoSpace = errors.New("ebml: not enough space for the element")
+
+// WriteVoid writes a Void element which occupies exactly n octets,
+// including its header, to reserve space for later use.
+//
+// A Void element needs at least two octets, so WriteVoid returns an
+// error when n is 1. It writes nothing when n is 0.
+func (e *Encoder) WriteVoid(n int64) error {
+	if n == 0 {
+		return nil
+	}
+	ds, w, err := voidSize(n)
+	if err != nil {
+		return err
+	}
+	if err := e.begin(IDVoid); err != nil {
+		return err
+	}
+	if _, err := e.w.WriteElementID(IDVoid); err != nil {
+		return err
+	}
+	if _, err := e.w.WriteElementDataSize(ds, w); err != nil {
+		return err
+	}
+	return writeZeros(e.w, ds)
+}
+
+// OverwriteElement writes the encoded element el over the n octets at
+// offset off of w, and fills the rest with a Void element so the data
+// following the n octets does not move.
+//
+// When a single octet would remain, the data size of el is written with
+// one more octet instead. OverwriteElement returns ErrNoSpace when el
+// does not fit.
+func OverwriteElement(w io.WriterAt, off, n int64, el []byte) error {
+	rest := n - int64(len(el))
+	if rest == 1 {
+		wider, err := widenDataSize(el)
+		if err != nil {
+			return err
+		}
+		el, rest = wider, 0
+	}
+	if rest < 0 {
+		return fmt.Errorf("%w: %d octets needed, %d available", ErrNoSpace, len(el), n)
+	}
+	if rest == 0 {
+		_, err := w.WriteAt(el, off)
+		return err
+	}
+	ds, sw, err := voidSize(rest)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, len(el)+1+sw)
+	copy(b, el)
+	b[len(el)] = byte(IDVoid) // the Element ID of Void is a single octet
+	if _, err := ebmltext.AppendVintData(uint64(ds), sw, b[len(el)+1:]); err != nil {
+		return err
+	}
+	if _, err := w.WriteAt(b, off); err != nil {
+		return err
+	}
+	return writeZeros(io.NewOffsetWriter(w, off+int64(len(b))), ds)
+}
+
+// voidSize returns the data size and the width of the data size of
+// a Void element which occupies n octets.
+func voidSize(n int64) (ds int64, w int, err error) {
+	for w = 1; w <= 8; w++ {
+		ds = n - 1 - int64(w)
+		if ds >= 0 && vintDataWidth(uint64(ds)) <= w {
+			return ds, w, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("%w: a Void element cannot occupy %d octets", ErrNoSpace, n)
+}
+
+// widenDataSize returns el with its data size written with one more octet.
+func widenDataSize(el []byte) ([]byte, error) {
+	_, idW, err := ebmltext.ParseElementID(el, 8)
+	if err != nil {
+		return nil, err
+	}
+	ds, sw, err := ebmltext.ParseElementDataSize(el[idW:], 8)
+	if err != nil {
+		return nil, err
+	}
+	if sw == 8 {
+		return nil, fmt.Errorf("%w: the data size cannot be widened", ErrNoSpace)
+	}
+	b := make([]byte, 0, len(el)+1)
+	b = append(b, el[:idW]...)
+	size := make([]byte, sw+1)
+	v := uint64(ds)
+	if ds == -1 {
+		v = 1<<(7*(sw+1)) - 1
+	}
+	if _, err := ebmltext.AppendVintData(v, sw+1, size); err != nil {
+		return nil, err
+	}
+	b = append(b, size...)
+	return append(b, el[idW+sw:]...), nil
+}
+
+// writeZeros writes n zero octets to w.
+func writeZeros(w io.Writer, n int64) error {
+	var zeros [4096]byte
+	for n > 0 {
+		k := min(n, int64(len(zeros)))
+		if _, err := w.Write(zeros[:k]); err != nil {
+			return err
+		}
+		n -= k
+	}
+	return nil
+}
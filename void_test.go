@@ -0,0 +1,97 @@
+package ebml
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/coding-socks/ebml/ebmltext"
+)
+
+func TestEncoder_WriteVoid(t *testing.T) {
+	for _, n := range []int64{2, 3, 128, 129, 130, 131, 1 << 14, 1<<14 + 2, 100000} {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).WriteVoid(n); err != nil {
+			t.Fatalf("WriteVoid(%d) error = %v", n, err)
+		}
+		if int64(buf.Len()) != n {
+			t.Errorf("WriteVoid(%d) wrote %d octets", n, buf.Len())
+		}
+		d := NewDecoder(bytes.NewReader(buf.Bytes()))
+		el, _, err := d.NextOf(RootEl, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if el.ID != IDVoid || int64(el.HeaderSize)+el.DataSize != n {
+			t.Errorf("WriteVoid(%d) = element %v of %d+%d octets", n, el.ID, el.HeaderSize, el.DataSize)
+		}
+	}
+	if err := NewEncoder(io.Discard).WriteVoid(1); !errors.Is(err, ErrNoSpace) {
+		t.Errorf("WriteVoid(1) error = %v, want ErrNoSpace", err)
+	}
+}
+
+// memWriterAt is an in-memory io.WriterAt.
+type memWriterAt []byte
+
+func (m memWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > int64(len(m)) {
+		return 0, io.ErrShortWrite
+	}
+	return copy(m[off:], p), nil
+}
+
+func TestOverwriteElement(t *testing.T) {
+	title := func(s string) []byte { return testElement(testIDTitle, []byte(s)) }
+	tests := []struct {
+		name    string
+		n       int64
+		el      []byte
+		wantErr error
+	}{
+		{name: "exact", n: 8, el: title("abcde")},
+		{name: "void filler", n: 20, el: title("abc")},
+		{name: "one octet left", n: 7, el: title("abc")},
+		{name: "large void filler", n: 300, el: title("abc")},
+		{name: "too large", n: 4, el: title("abc"), wantErr: ErrNoSpace},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bytes.Repeat([]byte{0xaa}, int(tt.n)+4)
+			err := OverwriteElement(memWriterAt(b), 2, tt.n, tt.el)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("OverwriteElement() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b[:2], []byte{0xaa, 0xaa}) || !bytes.Equal(b[2+tt.n:], []byte{0xaa, 0xaa}) {
+				t.Fatalf("OverwriteElement() wrote outside of the region: %x", b)
+			}
+			region := b[2 : 2+tt.n]
+			var ids []uint64
+			for len(region) > 0 {
+				id, idW, err := ebmltext.ParseElementID(region, 4)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ds, sW, err := ebmltext.ParseElementDataSize(region[idW:], 8)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, uint64(id))
+				if id == testIDTitle && string(region[idW+sW:idW+sW+int(ds)]) != string(tt.el[3:]) {
+					t.Errorf("Title = %q", region[idW+sW:idW+sW+int(ds)])
+				}
+				region = region[idW+sW+int(ds):]
+			}
+			if len(ids) == 0 || ids[0] != uint64(testIDTitle) || len(ids) > 1 && ids[1] != uint64(IDVoid) || len(ids) > 2 {
+				t.Errorf("OverwriteElement() wrote elements %x", ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,607 @@
+package ebml
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/coding-socks/ebml/ebmltext"
+	"github.com/coding-socks/ebml/schema"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// XMLAttr selects the attributes written by an XMLEncoder.
+type XMLAttr int
+
+const (
+	// XMLAttrID writes the Element ID in the id attribute.
+	XMLAttrID XMLAttr = 1 << iota
+	// XMLAttrSize writes the Element Data Size in the size attribute.
+	XMLAttrSize
+	// XMLAttrSizeLength writes the width of the Element Data Size in
+	// the sizelen attribute.
+	XMLAttrSizeLength
+	// XMLAttrOffset writes the input offset of the element in the
+	// offset attribute.
+	XMLAttrOffset
+)
+
+// XMLExact selects the attributes which XMLDecoder needs to write the
+// same octets as the original document.
+const XMLExact = XMLAttrSize | XMLAttrSizeLength
+
+const (
+	// xmlRootName is the name of the XML element which holds the EBML
+	// Header and the EBML Body.
+	xmlRootName = "EBMLDocument"
+	// xmlUnknownName is the name of the XML element of an element with
+	// UnknownSchema.
+	xmlUnknownName = "Unknown"
+)
+
+// An XMLEncoder writes EBML Documents as XML.
+//
+// Each element is an XML element named after the schema, such as
+// <Title>. The value of a leaf element is the text of the XML element:
+// integers in decimal, floats in their shortest form, dates in RFC 3339
+// format and binary data in hexadecimal. An element without data has no
+// text. Strings lose their trailing NUL octets, which are restored from the
+// size attribute. A value which is not valid XML text, such as a string
+// with invalid UTF-8, is written in hexadecimal with encoding="hex". An element with UnknownSchema is named Unknown and always
+// has an id attribute; a master element of unknown size and a Void element
+// always have a size attribute. The data of a Void element is not written.
+type XMLEncoder struct {
+	w     io.Writer
+	attrs XMLAttr
+
+	enc *xml.Encoder
+	d   *Decoder
+	// header holds the values of the EBML Header needed to read the EBML Body.
+	header struct {
+		docType        string
+		idLen, sizeLen uint64
+		inside         bool
+	}
+}
+
+// NewXMLEncoder returns a new XMLEncoder that writes to w.
+func NewXMLEncoder(w io.Writer) *XMLEncoder {
+	return &XMLEncoder{w: w}
+}
+
+// SetAttrs selects the attributes written with each element.
+func (x *XMLEncoder) SetAttrs(attrs XMLAttr) {
+	x.attrs = attrs
+}
+
+// Encode reads an EBML Document from r and writes it as XML.
+func (x *XMLEncoder) Encode(r io.Reader) error {
+	if _, err := io.WriteString(x.w, xml.Header); err != nil {
+		return err
+	}
+	x.enc = xml.NewEncoder(x.w)
+	x.enc.Indent("", "  ")
+	x.d = NewDecoder(r)
+	root := xml.StartElement{Name: xml.Name{Local: xmlRootName}}
+	if err := x.enc.EncodeToken(root); err != nil {
+		return err
+	}
+	d := x.d
+	for {
+		el, _, err := d.NextOf(RootEl, 0)
+		if isDamage(err) {
+			if err := d.recover(d.r.InputOffset(), err); err != nil {
+				return errors.Join(err, d.skippedErrs)
+			}
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if el.ID != IDEBML {
+			if err := x.element(el); err != nil {
+				return err
+			}
+			continue
+		}
+		d.def = HeaderDef
+		d.r.MaxIDLength = DefaultMaxIDLength
+		d.r.MaxSizeLength = DefaultMaxSizeLength
+		x.header.docType = ""
+		x.header.idLen, x.header.sizeLen = uint64(DefaultMaxIDLength), uint64(DefaultMaxSizeLength)
+		x.header.inside = true
+		err = x.element(el)
+		x.header.inside = false
+		if err != nil {
+			return err
+		}
+		if d.def, err = Definition(x.header.docType); err != nil {
+			return err
+		}
+		d.r.MaxIDLength, d.r.MaxSizeLength = uint(x.header.idLen), uint(x.header.sizeLen)
+	}
+	if err := x.enc.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	if err := x.enc.Close(); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(x.w, "\n"); err != nil {
+		return err
+	}
+	return d.skippedErrs
+}
+
+// element writes el and its children.
+func (x *XMLEncoder) element(el Element) error {
+	start := xml.StartElement{Name: xml.Name{Local: el.Schema.Name}}
+	unknown := el.Schema.Name == UnknownSchema.Name
+	if unknown {
+		start.Name.Local = xmlUnknownName
+	}
+	attr := func(name, value string) {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+	}
+	if x.attrs&XMLAttrID != 0 || unknown {
+		attr("id", el.ID.String())
+	}
+	if x.attrs&XMLAttrSize != 0 || el.DataSize == -1 || el.ID == IDVoid {
+		size := "unknown"
+		if el.DataSize != -1 {
+			size = strconv.FormatInt(el.DataSize, 10)
+		}
+		attr("size", size)
+	}
+	if x.attrs&XMLAttrSizeLength != 0 {
+		attr("sizelen", strconv.Itoa(el.HeaderSize-len(ebmltext.AppendUint(nil, uint64(el.ID)))))
+	}
+	if x.attrs&XMLAttrOffset != 0 {
+		attr("offset", strconv.FormatInt(el.Offset, 10))
+	}
+	var text string
+	if el.Schema.Type != TypeMaster && el.ID != IDVoid {
+		b, err := x.d.readAlloc(el, x.d.scratch)
+		if err != nil {
+			return err
+		}
+		if text, err = formatXMLValue(el.Schema.Type, b); err != nil {
+			return newElementError(el, err)
+		}
+		if !isXMLText(text) {
+			// XML would replace the octets which are not valid text.
+			attr("encoding", "hex")
+			text = hex.EncodeToString(b)
+		}
+		if x.header.inside {
+			switch el.ID {
+			case IDDocType:
+				x.header.docType = text
+			case IDEBMLMaxIDLength:
+				x.header.idLen, _ = ebmltext.Uint(b)
+			case IDEBMLMaxSizeLength:
+				x.header.sizeLen, _ = ebmltext.Uint(b)
+			}
+		}
+	}
+	if err := x.enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch {
+	case el.Schema.Type == TypeMaster:
+		if err := x.d.DecodeChildren(el, x.element); err != nil {
+			return err
+		}
+	case el.ID == IDVoid:
+		if err := x.d.Skip(el); err != nil {
+			return err
+		}
+	default:
+		if err := x.enc.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+	return x.enc.EncodeToken(start.End())
+}
+
+// formatXMLValue returns the text of the data b of an element of type typ.
+func formatXMLValue(typ string, b []byte) (string, error) {
+	if len(b) == 0 {
+		return "", nil
+	}
+	switch typ {
+	case TypeInteger:
+		i, err := ebmltext.Int(b)
+		return strconv.FormatInt(i, 10), err
+	case TypeUinteger:
+		u, err := ebmltext.Uint(b)
+		return strconv.FormatUint(u, 10), err
+	case TypeFloat:
+		f, err := ebmltext.Float(b)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(f, 'g', -1, 8*len(b)), nil
+	case TypeString, TypeUTF8:
+		return strings.TrimRight(string(b), "\x00"), nil
+	case TypeDate:
+		if len(b) != 8 {
+			return "", errors.New("ebml: data length must be 0 bit or 64 bit for a date")
+		}
+		t, err := ebmltext.Date(b)
+		return t.Format(time.RFC3339Nano), err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// isXMLText reports whether s only holds characters allowed in XML.
+func isXMLText(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r < 0x20 && r != '\t' && r != '\n' && r != '\r':
+			return false
+		case r >= 0xd800 && r <= 0xdfff, r == 0xfffe, r == 0xffff:
+			return false
+		}
+	}
+	return true
+}
+
+// An XMLDecoder reads the XML written by an XMLEncoder and writes the
+// EBML Document it describes.
+type XMLDecoder struct {
+	r io.Reader
+}
+
+// NewXMLDecoder returns a new XMLDecoder that reads from r.
+func NewXMLDecoder(r io.Reader) *XMLDecoder {
+	return &XMLDecoder{r: r}
+}
+
+// xmlNode is an element read from XML.
+type xmlNode struct {
+	id       schema.ElementID
+	master   bool
+	data     []byte
+	children []*xmlNode
+	// size is the data size, or -1 for unknown data size.
+	size int64
+	// sizeLen is the minimum width of the data size.
+	sizeLen int
+}
+
+// Decode reads the XML document and writes the EBML Document to w.
+//
+// Elements are looked up by their id attribute, or by name inside their
+// parent. The size attribute is the minimum width of an integer, the width
+// of a float, the length of a string padded with NUL octets, and the data
+// size of a Void element; a master element has unknown data size when it
+// is "unknown". The sizelen attribute is the minimum width of the data
+// size. The value of an element with encoding="hex" is its data in
+// hexadecimal. The offset attribute is ignored.
+func (x *XMLDecoder) Decode(w io.Writer) error {
+	xd := xml.NewDecoder(x.r)
+	var root xml.StartElement
+	for {
+		t, err := xd.Token()
+		if err == io.EOF {
+			return fmt.Errorf("ebml: missing %s XML element", xmlRootName)
+		}
+		if err != nil {
+			return err
+		}
+		if start, ok := t.(xml.StartElement); ok {
+			root = start
+			break
+		}
+	}
+	if root.Name.Local != xmlRootName {
+		return fmt.Errorf("ebml: XML element %s, want %s", root.Name.Local, xmlRootName)
+	}
+	var nodes []*xmlNode
+	def := HeaderDef
+	params := make(map[*xmlNode][2]uint)
+	err := xmlChildren(xd, func(start xml.StartElement) error {
+		d := def
+		if start.Name.Local == "EBML" {
+			d = HeaderDef
+		}
+		n, err := parseXMLNode(xd, start, d, "")
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, n)
+		if n.id == IDEBML {
+			docType, idLen, sizeLen := n.headerParams()
+			params[n] = [2]uint{idLen, sizeLen}
+			def, err = Definition(docType)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := ebmltext.NewEncoder(bw)
+	for _, n := range nodes {
+		n.computeSize()
+		p, header := params[n]
+		if header {
+			enc.MaxIDLength, enc.MaxSizeLength = DefaultMaxIDLength, DefaultMaxSizeLength
+		}
+		if err := n.write(enc); err != nil {
+			return err
+		}
+		if header {
+			enc.MaxIDLength, enc.MaxSizeLength = p[0], p[1]
+		}
+	}
+	return bw.Flush()
+}
+
+// xmlChildren calls f for each XML element until the end of the current
+// one. Text other than white space is an error.
+func xmlChildren(xd *xml.Decoder, f func(start xml.StartElement) error) error {
+	for {
+		t, err := xd.Token()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			if err := f(t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		case xml.CharData:
+			if len(strings.TrimSpace(string(t))) != 0 {
+				line, _ := xd.InputPos()
+				return fmt.Errorf("ebml: unexpected text in XML on line %d", line)
+			}
+		}
+	}
+}
+
+// parseXMLNode reads the XML element which starts with start, inside the
+// element with the schema path parent.
+func parseXMLNode(xd *xml.Decoder, start xml.StartElement, def *Def, parent string) (*xmlNode, error) {
+	line, _ := xd.InputPos()
+	fail := func(err error) error {
+		return fmt.Errorf("ebml: XML element %s on line %d: %w", start.Name.Local, line, err)
+	}
+	var sch schema.Element
+	var ok bool
+	size, hasSize, hexData := int64(0), false, false
+	n := &xmlNode{}
+	for _, a := range start.Attr {
+		var err error
+		switch a.Name.Local {
+		case "id":
+			var id uint64
+			if id, err = strconv.ParseUint(a.Value, 0, 64); err == nil {
+				if sch, ok = def.Get(schema.ElementID(id)); !ok {
+					sch.ID = schema.ElementID(id)
+				}
+				ok = true
+			}
+		case "size":
+			hasSize = true
+			if a.Value == "unknown" {
+				size = -1
+				break
+			}
+			size, err = strconv.ParseInt(a.Value, 10, 64)
+		case "sizelen":
+			n.sizeLen, err = strconv.Atoi(a.Value)
+		case "encoding":
+			if hexData = a.Value == "hex"; !hexData {
+				err = fmt.Errorf("unknown encoding %q", a.Value)
+			}
+		}
+		if err != nil {
+			return nil, fail(fmt.Errorf("attribute %s: %w", a.Name.Local, err))
+		}
+	}
+	if !ok {
+		if sch, ok = def.childByName(parent, start.Name.Local); !ok {
+			return nil, fail(errors.New("unknown element"))
+		}
+	}
+	n.id = sch.ID
+	if sch.Type == TypeMaster {
+		n.master = true
+		if hasSize && size == -1 {
+			n.size = -1
+		}
+		err := xmlChildren(xd, func(start xml.StartElement) error {
+			child, err := parseXMLNode(xd, start, def, sch.Path)
+			n.children = append(n.children, child)
+			return err
+		})
+		return n, err
+	}
+	if hasSize && size == -1 {
+		return nil, fail(ErrUnknownSizeNotAllowed)
+	}
+	var text strings.Builder
+	for {
+		t, err := xd.Token()
+		if err == io.EOF {
+			return nil, fail(io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, end := t.(xml.EndElement); end {
+			break
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			return nil, fail(fmt.Errorf("unexpected XML element %s in %s element", t.Name.Local, sch.Type))
+		case xml.CharData:
+			text.Write(t)
+		}
+	}
+	if sch.ID == IDVoid {
+		n.data = make([]byte, max(size, 0))
+		return n, nil
+	}
+	var err error
+	if hexData {
+		n.data, err = parseXMLValue(TypeBinary, text.String(), size, hasSize)
+	} else {
+		n.data, err = parseXMLValue(sch.Type, text.String(), size, hasSize)
+	}
+	if err != nil {
+		return nil, fail(err)
+	}
+	return n, nil
+}
+
+// parseXMLValue returns the data of an element of type typ from its text.
+// When hasSize is true, size is the width of the original data.
+func parseXMLValue(typ, text string, size int64, hasSize bool) ([]byte, error) {
+	if typ != TypeString && typ != TypeUTF8 {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return nil, nil
+		}
+	}
+	switch typ {
+	case TypeInteger:
+		i, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		b := ebmltext.AppendInt(nil, i)
+		pad := byte(0)
+		if i < 0 {
+			pad = 0xff
+		}
+		return padData(b, size, pad, 8), nil
+	case TypeUinteger:
+		u, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return padData(ebmltext.AppendUint(nil, u), size, 0, 8), nil
+	case TypeFloat:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, err
+		}
+		if hasSize && size == 4 && float64(float32(f)) == f {
+			return ebmltext.AppendFloat(nil, f, 4), nil
+		}
+		return ebmltext.AppendFloat(nil, f, 8), nil
+	case TypeString, TypeUTF8:
+		b := []byte(text)
+		if size > int64(len(b)) {
+			b = append(b, make([]byte, size-int64(len(b)))...)
+		}
+		return b, nil
+	case TypeDate:
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, err
+		}
+		return ebmltext.AppendDate(nil, t), nil
+	}
+	return hex.DecodeString(strings.Join(strings.Fields(text), ""))
+}
+
+// padData returns b with pad octets prepended up to size octets, when
+// size does not exceed limit.
+func padData(b []byte, size int64, pad byte, limit int) []byte {
+	if size <= int64(len(b)) || size > int64(limit) {
+		return b
+	}
+	p := make([]byte, size-int64(len(b)), size)
+	for i := range p {
+		p[i] = pad
+	}
+	return append(p, b...)
+}
+
+// headerParams returns the values of the EBML Header node n which are
+// needed to write the EBML Body.
+func (n *xmlNode) headerParams() (docType string, idLen, sizeLen uint) {
+	idLen, sizeLen = DefaultMaxIDLength, DefaultMaxSizeLength
+	for _, c := range n.children {
+		switch c.id {
+		case IDDocType:
+			docType = strings.TrimRight(string(c.data), "\x00")
+		case IDEBMLMaxIDLength:
+			u, _ := ebmltext.Uint(c.data)
+			idLen = uint(u)
+		case IDEBMLMaxSizeLength:
+			u, _ := ebmltext.Uint(c.data)
+			sizeLen = uint(u)
+		}
+	}
+	return docType, idLen, sizeLen
+}
+
+// computeSize sets the data size of n and its descendants, and returns
+// the number of octets n occupies.
+func (n *xmlNode) computeSize() int64 {
+	if !n.master {
+		n.size = int64(len(n.data))
+	} else {
+		var size int64
+		for _, c := range n.children {
+			size += c.computeSize()
+		}
+		if n.size != -1 {
+			n.size = size
+		}
+		if n.size == -1 {
+			return n.headerSize() + size
+		}
+	}
+	return n.headerSize() + n.size
+}
+
+// headerSize returns the number of octets of the header of n.
+func (n *xmlNode) headerSize() int64 {
+	w := max(n.sizeLen, 1)
+	if n.size != -1 {
+		w = max(vintDataWidth(uint64(n.size)), n.sizeLen)
+	}
+	return int64(len(ebmltext.AppendUint(nil, uint64(n.id))) + w)
+}
+
+// write writes n and its descendants.
+func (n *xmlNode) write(enc *ebmltext.Encoder) error {
+	if _, err := enc.WriteElementID(n.id); err != nil {
+		return err
+	}
+	if _, err := enc.WriteElementDataSize(n.size, n.sizeLen); err != nil {
+		return err
+	}
+	if !n.master {
+		_, err := enc.Write(n.data)
+		return err
+	}
+	for _, c := range n.children {
+		if err := c.write(enc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
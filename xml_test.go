@@ -0,0 +1,162 @@
+package ebml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/coding-socks/ebml/ebmltext"
+)
+
+// testXMLDocument returns a document with values and data sizes which do
+// not use their shortest form.
+func testXMLDocument(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.Write(testHeader("test"))
+	enc := ebmltext.NewEncoder(&buf)
+	if _, err := enc.WriteElementID(testIDTest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.WriteElementDataSize(-1, 8); err != nil {
+		t.Fatal(err)
+	}
+	info := testElement(testIDInfo,
+		testElement(testIDTitle, []byte("title\x00\x00")),
+		testElement(testIDTimestampScale, []byte{0, 0, 0x03, 0xe8}),
+		testElement(testIDDuration, []byte{0x41, 0x48, 0, 0}),
+		testElement(testIDOffset, []byte{0xff, 0xfe}),
+		testElement(testIDDateUTC, nil),
+		testElement(IDVoid, []byte{0, 0, 0}),
+	)
+	buf.Write(info)
+	if _, err := enc.WriteElementID(testIDCluster); err != nil {
+		t.Fatal(err)
+	}
+	cluster := bytes.Join([][]byte{
+		testElement(testIDTimestamp, []byte{1}),
+		testElement(testIDPayload, []byte{1, 2, 3}),
+		testElement(0x4001, []byte{4}),
+	}, nil)
+	if _, err := enc.WriteElementDataSize(int64(len(cluster)), 4); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(cluster)
+	return buf.Bytes()
+}
+
+func TestXMLEncoder(t *testing.T) {
+	doc := testXMLDocument(t)
+	var out bytes.Buffer
+	enc := NewXMLEncoder(&out)
+	enc.SetAttrs(XMLAttrID | XMLAttrOffset)
+	if err := enc.Encode(bytes.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>` + "\n<EBMLDocument>\n",
+		`  <EBML id="0x1a45dfa3" offset="0">`,
+		`    <DocType id="0x4282" offset="`,
+		`>test</DocType>`,
+		`  <Test id="0x18538067" size="unknown" offset="20">`,
+		`>title</Title>`,
+		`>1000</TimestampScale>`,
+		`>12.5</Duration>`,
+		`>-2</Offset>`,
+		`></DateUTC>`,
+		`<Void id="0xec" size="3" offset="`,
+		`>010203</Payload>`,
+		`<Unknown id="0x4001" offset="`,
+		`>04</Unknown>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("XML does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestXMLDecoder(t *testing.T) {
+	doc := testXMLDocument(t)
+	var x bytes.Buffer
+	enc := NewXMLEncoder(&x)
+	enc.SetAttrs(XMLExact | XMLAttrOffset)
+	if err := enc.Encode(bytes.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := NewXMLDecoder(bytes.NewReader(x.Bytes())).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), doc) {
+		t.Errorf("Decode() =\n%x\nwant\n%x\nXML:\n%s", out.Bytes(), doc, x.Bytes())
+	}
+}
+
+func TestXMLDecoder_invalidText(t *testing.T) {
+	for _, title := range []string{"a\xff\x01b", "a\x01", "ab\x00cd"} {
+		doc := append(testHeader("test"), testElement(testIDTest,
+			testElement(testIDInfo, testElement(testIDTitle, []byte(title))),
+		)...)
+		var x bytes.Buffer
+		enc := NewXMLEncoder(&x)
+		enc.SetAttrs(XMLExact)
+		if err := enc.Encode(bytes.NewReader(doc)); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(x.Bytes(), []byte(`encoding="hex"`)) {
+			t.Errorf("Title %q is not written in hexadecimal:\n%s", title, x.Bytes())
+		}
+		var out bytes.Buffer
+		if err := NewXMLDecoder(bytes.NewReader(x.Bytes())).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), doc) {
+			t.Errorf("Decode() with Title %q =\n%x\nwant\n%x\nXML:\n%s", title, out.Bytes(), doc, x.Bytes())
+		}
+	}
+}
+
+func TestXMLDecoder_handwritten(t *testing.T) {
+	in := `<EBMLDocument>
+	<EBML><DocType>test</DocType></EBML>
+	<Test>
+		<Info><Title>abc</Title><Offset>-2</Offset></Info>
+		<Cluster size="unknown"><Timestamp>5</Timestamp><Payload> 01 02 </Payload></Cluster>
+	</Test>
+</EBMLDocument>`
+	var out bytes.Buffer
+	if err := NewXMLDecoder(strings.NewReader(in)).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDecoder(&out)
+	if _, err := d.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var got testDocument
+	if err := d.DecodeBody(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Info.Title != "abc" || got.Info.Offset != -2 || len(got.Cluster) != 1 || got.Cluster[0].Timestamp != 5 || !bytes.Equal(got.Cluster[0].Payload[0], []byte{1, 2}) {
+		t.Errorf("document = %+v", got)
+	}
+}
+
+func TestXMLDecoder_errors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`<Other/>`, "want EBMLDocument"},
+		{`<EBMLDocument><EBML><DocType>test</DocType></EBML><Test><Missing/></Test></EBMLDocument>`, "unknown element"},
+		{`<EBMLDocument><EBML><DocType>test</DocType></EBML><Test><Info><Offset>x</Offset></Info></Test></EBMLDocument>`, "Offset on line 1"},
+		{`<EBMLDocument><EBML><DocType>test</DocType></EBML><Test>text</Test></EBMLDocument>`, "unexpected text"},
+		{`<EBMLDocument><EBML><DocType>test</DocType></EBML><Test><Info><Title size="unknown"/></Info></Test></EBMLDocument>`, "unknown size"},
+	}
+	for _, tt := range tests {
+		err := NewXMLDecoder(strings.NewReader(tt.in)).Decode(&bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Decode(%s) error = %v, want %q", tt.in, err, tt.want)
+		}
+	}
+}